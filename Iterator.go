@@ -2,6 +2,7 @@ package bitcask_go
 
 import (
 	"bytes"
+	"context"
 	"myRosedb/index"
 )
 
@@ -15,17 +16,27 @@ type Iterator struct {
 
 	// 传入用户的索引迭代器配置项
 	options IteratorOptions
+
+	// ctx 被取消之后迭代器变为无效
+	ctx context.Context
 }
 
 // NewIterator 初始化迭代器，属于DB结构体
 func (db *DB) NewIterator(opts IteratorOptions) *Iterator {
+	return db.NewIteratorCtx(context.Background(), opts)
+}
+
+// NewIteratorCtx 初始化迭代器，ctx 被取消之后 Valid 返回 false，可以通过 Err 获取取消的原因
+func (db *DB) NewIteratorCtx(ctx context.Context, opts IteratorOptions) *Iterator {
 	// 用 db 获取索引
 	indexIter := db.index.Iterator(opts.Reverse)
+	db.metrics.iteratorCount.Inc()
 
 	return &Iterator{
 		indexIter: indexIter,
 		db:        db,
 		options:   opts,
+		ctx:       ctx,
 	}
 }
 
@@ -49,9 +60,17 @@ func (it *Iterator) Next() {
 
 // Valid 是否有效，即是否已经完成遍历完所有的key，用于退出遍历
 func (it *Iterator) Valid() bool {
+	if it.ctx.Err() != nil {
+		return false
+	}
 	return it.indexIter.Valid()
 }
 
+// Err 返回迭代器因为 ctx 被取消而终止的原因，没有被取消时返回 nil
+func (it *Iterator) Err() error {
+	return it.ctx.Err()
+}
+
 // Key 当前遍历位置的 key
 func (it *Iterator) Key() []byte {
 	return it.indexIter.Key()
@@ -60,6 +79,9 @@ func (it *Iterator) Key() []byte {
 // Value 当前遍历位置的 Value 数据
 // 将btreeIterator返回的位置信息进行处理
 func (it *Iterator) Value() ([]byte, error) {
+	if err := it.ctx.Err(); err != nil {
+		return nil, err
+	}
 	logRecordPos := it.indexIter.Value()
 	it.db.mu.RLock()
 	defer it.db.mu.RUnlock()
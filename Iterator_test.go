@@ -1,6 +1,7 @@
 package bitcask_go
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
 	"myRosedb/utils"
 	"os"
@@ -96,3 +97,35 @@ func TestDB_Iterator_Multi_Value(t *testing.T) {
 		t.Log(string(iter3.Key()))
 	}
 }
+
+func TestDB_NewIteratorCtx(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-iterator-ctx")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 10; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(10))
+		assert.Nil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iterator := db.NewIteratorCtx(ctx, DefaultIteratorOptions)
+	defer iterator.Close()
+
+	var count int
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		count++
+		if count == 3 {
+			cancel()
+		}
+	}
+	assert.Equal(t, 3, count)
+	assert.Equal(t, context.Canceled, iterator.Err())
+	_, err = iterator.Value()
+	assert.Equal(t, context.Canceled, err)
+}
@@ -17,7 +17,10 @@ type WriteBatch struct {
 	options       WriteBatchOptions
 	mu            *sync.RWMutex
 	db            *DB
-	pendingWrites map[string]*data.LogRecord // 暂存用户写入的数据
+	pendingWrites []data.LogRecord     // 按照写入顺序暂存用户写入的数据
+	pendingKeys   map[string]int       // 每个 key 最后一次写入在 pendingWrites 中的位置
+	pendingSize   int64                // 暂存数据中 key 和 value 的总长度
+	positions     []*data.LogRecordPos // 提交时暂存每条数据写入的位置，提交之后复用
 }
 
 // NewWriteBatch 初始化 WriteBach 的方法
@@ -27,10 +30,10 @@ func (db *DB) NewWriteBatch(opts WriteBatchOptions) *WriteBatch {
 		panic("cannot use write batch, seq no file not exists")
 	}
 	return &WriteBatch{
-		options:       opts,
-		mu:            new(sync.RWMutex),
-		db:            db,
-		pendingWrites: make(map[string]*data.LogRecord),
+		options:     opts,
+		mu:          new(sync.RWMutex),
+		db:          db,
+		pendingKeys: make(map[string]int),
 	}
 }
 
@@ -39,13 +42,14 @@ func (wb *WriteBatch) Put(key []byte, value []byte) error {
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
+	if err := wb.db.checkKeyValueSize(key, int64(len(value))); err != nil {
+		return err
+	}
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 
 	// 暂存 LogRecord
-	logRecord := &data.LogRecord{Key: key, Value: value}
-	wb.pendingWrites[string(key)] = logRecord
-	return nil
+	return wb.appendPending(data.LogRecord{Key: key, Value: value})
 }
 
 // Delete 删除数据
@@ -57,32 +61,107 @@ func (wb *WriteBatch) Delete(key []byte) error {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 
-	// 数据不存在则直接返回
-	logRecordPos := wb.db.index.Get(key)
-	if logRecordPos == nil {
-		if wb.pendingWrites[string(key)] != nil {
-			delete(wb.pendingWrites, string(key))
-		}
+	// 数据不存在，并且批次中也没有写入过，则直接返回
+	if _, ok := wb.pendingKeys[string(key)]; !ok && wb.db.index.Get(key) == nil {
 		return nil
 	}
 
 	//暂存 LogRecord
-	logRecord := &data.LogRecord{Key: key, Type: data.LogRecordDeleted}
-	wb.pendingWrites[string(key)] = logRecord
+	return wb.appendPending(data.LogRecord{Key: key, Type: data.LogRecordDeleted})
+}
+
+// 按照顺序暂存一条数据，超过 MaxBatchBytes 时拒绝写入
+// 在访问此方法前必须持有互斥锁
+func (wb *WriteBatch) appendPending(logRecord data.LogRecord) error {
+	size := int64(len(logRecord.Key) + len(logRecord.Value))
+	if wb.options.MaxBatchBytes > 0 && wb.pendingSize+size > wb.options.MaxBatchBytes {
+		return ErrExceedMaxBatchBytes
+	}
+	wb.pendingKeys[string(logRecord.Key)] = len(wb.pendingWrites)
+	wb.pendingWrites = append(wb.pendingWrites, logRecord)
+	wb.pendingSize += size
 	return nil
 }
 
-// Commit 提交事务，将暂存的数据写到数据文件，并更新内存索引
+// Get 读取 key 对应的数据，优先读取批次中还没有提交的数据，没有写入过的 key 从数据库中读取
+func (wb *WriteBatch) Get(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	wb.mu.RLock()
+	if i, ok := wb.pendingKeys[string(key)]; ok {
+		logRecord := wb.pendingWrites[i]
+		wb.mu.RUnlock()
+		if logRecord.Type == data.LogRecordDeleted {
+			return nil, ErrKeyNotFound
+		}
+		return logRecord.Value, nil
+	}
+	wb.mu.RUnlock()
+	return wb.db.Get(key)
+}
+
+// Len 批次中暂存的操作数量，同一个 key 的多次写入分别计算
+func (wb *WriteBatch) Len() int {
+	wb.mu.RLock()
+	defer wb.mu.RUnlock()
+	return len(wb.pendingWrites)
+}
+
+// Size 批次中暂存的 key 和 value 的总长度
+func (wb *WriteBatch) Size() int64 {
+	wb.mu.RLock()
+	defer wb.mu.RUnlock()
+	return wb.pendingSize
+}
+
+// Rollback 丢弃批次中所有还没有提交的数据，之后可以继续使用
+func (wb *WriteBatch) Rollback() {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.clearPending()
+}
+
+// Reset 丢弃批次中所有还没有提交的数据，并使用新的配置项
+func (wb *WriteBatch) Reset(opts WriteBatchOptions) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.clearPending()
+	wb.options = opts
+}
+
+// 清空暂存数据，保留已经分配的空间，方便下一次使用
+// 在访问此方法前必须持有互斥锁
+func (wb *WriteBatch) clearPending() {
+	for i := range wb.pendingWrites {
+		wb.pendingWrites[i] = data.LogRecord{}
+	}
+	wb.pendingWrites = wb.pendingWrites[:0]
+	for i := range wb.positions {
+		wb.positions[i] = nil
+	}
+	wb.positions = wb.positions[:0]
+	clear(wb.pendingKeys)
+	wb.pendingSize = 0
+}
+
+// Commit 提交事务，将暂存的数据按照写入顺序写到数据文件，并更新内存索引
 func (wb *WriteBatch) Commit() error {
+	_, err := wb.CommitWithSeq()
+	return err
+}
+
+// CommitWithSeq 提交事务，返回这个事务分配的序列号，批次中的所有数据共用这个序列号，没有数据时返回 0
+func (wb *WriteBatch) CommitWithSeq() (uint64, error) {
 	wb.mu.Lock()
 	defer wb.mu.Unlock()
 
 	if len(wb.pendingWrites) == 0 {
-		return nil
+		return 0, nil
 	}
 
 	if uint(len(wb.pendingWrites)) > wb.options.MaxBatchNum {
-		return ErrExceedMaxBatchNum
+		return 0, ErrExceedMaxBatchNum
 	}
 
 	// 加锁保证事务提交的串行化
@@ -95,18 +174,21 @@ func (wb *WriteBatch) Commit() error {
 	seqNo := atomic.AddUint64(&wb.db.seqNo, 1)
 
 	// 开始写数据到数据文件当中
-	positions := make(map[string]*data.LogRecordPos)
-	for _, record := range wb.pendingWrites {
+	version := wb.db.newRecordVersion(seqNo)
+	for i := range wb.pendingWrites {
+		record := &wb.pendingWrites[i]
 		logRecordPos, err := wb.db.appendLogRecord(&data.LogRecord{
-			Key:   logRecordKeyWithSeq(record.Key, seqNo),
-			Value: record.Value,
-			Type:  record.Type,
+			Key:     logRecordKeyWithSeq(record.Key, seqNo),
+			Value:   record.Value,
+			Type:    record.Type,
+			Version: version,
 		})
 		if err != nil {
-			return err
+			wb.positions = wb.positions[:0]
+			return 0, err
 		}
 		// 索引等到所有数据写完再更新，所以先暂时将他们暂存起来
-		positions[string(record.Key)] = logRecordPos
+		wb.positions = append(wb.positions, logRecordPos)
 
 	}
 	// 写一条标识事务完成提交的数据，是保证原子性的关键
@@ -115,20 +197,25 @@ func (wb *WriteBatch) Commit() error {
 		Type: data.LogRecordTxnFinished,
 	}
 	// 此时所有的数据已经持久化到数据文件当中
-	if _, err := wb.db.appendLogRecord(finishedRecord); err != nil {
-		return err
+	finishedPos, err := wb.db.appendLogRecord(finishedRecord)
+	if err != nil {
+		wb.positions = wb.positions[:0]
+		return 0, err
 	}
+	wb.db.addDeadBytes(finishedPos)
 
 	// 根据配置决定是否进行持久化
 	if wb.options.SyncWrites && wb.db.activeFile != nil {
-		if err := wb.db.activeFile.Sync(); err != nil {
-			return err
+		if err := wb.db.syncActiveFile(); err != nil {
+			wb.positions = wb.positions[:0]
+			return 0, err
 		}
 	}
 
-	// 更新内存索引
-	for _, record := range wb.pendingWrites {
-		pos := positions[string(record.Key)]
+	// 按照写入顺序更新内存索引
+	for i := range wb.pendingWrites {
+		record := &wb.pendingWrites[i]
+		pos := wb.positions[i]
 		var oldPos *data.LogRecordPos
 		if record.Type == data.LogRecordNormal {
 			oldPos = wb.db.index.Put(record.Key, pos)
@@ -138,13 +225,21 @@ func (wb *WriteBatch) Commit() error {
 		}
 		if oldPos != nil {
 			wb.db.reclaimSize += int64(oldPos.Size)
+			wb.db.valueCache.Remove(valueCacheKey(oldPos))
+		}
+		wb.db.addFileUsage(pos, oldPos)
+		wb.db.versions.Add(record.Key, pos)
+		if record.Type == data.LogRecordNormal {
+			wb.db.indexWrite(record.Key, record.Value)
+		} else {
+			wb.db.indexDelete(record.Key)
 		}
 	}
 
 	// 清空暂存数据，方便下一次commit
-	wb.pendingWrites = make(map[string]*data.LogRecord)
+	wb.clearPending()
 
-	return nil
+	return seqNo, nil
 }
 
 // key+Seq Number 编码
@@ -96,7 +96,8 @@ func TestDB_WriteBatch2(t *testing.T) {
 	t.Log(err)
 	assert.Equal(t, ErrKeyNotFound, err)
 
-	assert.Equal(t, uint64(2), db.seqNo)
+	// 非事务的 Put 同样分配一个序列号
+	assert.Equal(t, uint64(3), db.seqNo)
 }
 
 func TestDB_WriteBatch3(t *testing.T) {
@@ -122,3 +123,77 @@ func TestDB_WriteBatch3(t *testing.T) {
 	assert.Nil(t, err)
 
 }
+
+func TestDB_WriteBatchOrdered(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-batch-ordered")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("exist"), []byte("value")))
+
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put([]byte("name"), []byte("v1")))
+	assert.Nil(t, wb.Delete([]byte("name")))
+	// 读取批次中还没有提交的数据
+	_, err = wb.Get([]byte("name"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Nil(t, wb.Put([]byte("name"), []byte("v2")))
+	val, err := wb.Get([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+	val, err = wb.Get([]byte("exist"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	// 不存在的 key 不需要删除
+	assert.Nil(t, wb.Delete([]byte("unknown")))
+	assert.Equal(t, 3, wb.Len())
+	assert.Equal(t, int64(16), wb.Size())
+
+	// 按照写入顺序提交，最后一次写入生效
+	assert.Nil(t, wb.Commit())
+	assert.Equal(t, 0, wb.Len())
+	assert.Equal(t, int64(0), wb.Size())
+	val, err = db.Get([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+
+	// 提交之后复用已经分配的空间
+	buf := wb.pendingWrites[:1]
+	assert.Nil(t, wb.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, wb.Delete([]byte("exist")))
+	assert.Equal(t, &buf[0], &wb.pendingWrites[0])
+	assert.Nil(t, wb.Commit())
+	_, err = db.Get([]byte("exist"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 回滚之后的数据不会写入
+	assert.Nil(t, wb.Put([]byte("b"), []byte("2")))
+	wb.Rollback()
+	assert.Equal(t, 0, wb.Len())
+	assert.Nil(t, wb.Commit())
+	_, err = db.Get([]byte("b"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 超过字节数的上限时拒绝写入
+	wb.Reset(WriteBatchOptions{MaxBatchNum: 10, MaxBatchBytes: 10})
+	assert.Nil(t, wb.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, ErrExceedMaxBatchBytes, wb.Put([]byte("key"), []byte("value")))
+	assert.Equal(t, 1, wb.Len())
+	assert.Nil(t, wb.Commit())
+
+	// 重启之后按照写入顺序恢复
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	val, err = db2.Get([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+	_, err = db2.Get([]byte("exist"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err = db2.Get([]byte("key"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	assert.Nil(t, db2.Close())
+}
@@ -0,0 +1,65 @@
+package benchmark
+
+import (
+	"math/rand"
+	bitcask_go "myRosedb"
+	"myRosedb/utils"
+	"os"
+	"testing"
+)
+
+// 结果与机器和文件系统有关，这里不记录具体数值，可以通过下面的命令运行对比：
+// go test -run xxx -bench 'Benchmark_Get' -benchtime=20000x ./benchmark
+
+// 对比旧的数据文件的读取是否使用直接 IO，以及是否开启 value 缓存
+func benchmarkGetWithOptions(b *testing.B, options bitcask_go.Options) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-bench-direct-io")
+	defer os.RemoveAll(dir)
+	options.DirPath = dir
+	options.DataFileSize = 8 * 1024 * 1024
+	options.MMapAtStartup = false
+
+	db, err := bitcask_go.Open(options)
+	if err != nil {
+		b.Skip(err)
+	}
+	defer db.Close()
+	for i := 0; i < 10000; i++ {
+		if err := db.Put(utils.GetTestKey(i), utils.RandomValue(1024)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// 热点数据集中在一小部分 key 上
+		_, err := db.Get(utils.GetTestKey(rand.Intn(1000)))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_GetStandardIO(b *testing.B) {
+	benchmarkGetWithOptions(b, bitcask_go.DefaultOptions)
+}
+
+func Benchmark_GetDirectIO(b *testing.B) {
+	options := bitcask_go.DefaultOptions
+	options.DirectIO = bitcask_go.DirectIOAll
+	benchmarkGetWithOptions(b, options)
+}
+
+func Benchmark_GetStandardIOWithCache(b *testing.B) {
+	options := bitcask_go.DefaultOptions
+	options.ValueCacheSize = 4 * 1024 * 1024
+	benchmarkGetWithOptions(b, options)
+}
+
+func Benchmark_GetDirectIOWithCache(b *testing.B) {
+	options := bitcask_go.DefaultOptions
+	options.DirectIO = bitcask_go.DirectIOAll
+	options.ValueCacheSize = 4 * 1024 * 1024
+	benchmarkGetWithOptions(b, options)
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"container/list"
+	"myRosedb/metrics"
+	"sync"
+)
+
+// 每个缓存项除了 value 之外额外占用的内存估算值（链表节点、map 项等）
+const entryOverhead = 64
+
+// Key 缓存的键，即 LogRecord 所在的文件 id 以及文件中的偏移
+// 数据文件只会追加写入，同一个位置上的数据不会改变，只有文件被删除或者替换时才需要失效
+type Key struct {
+	Fid    uint32
+	Offset int64
+}
+
+type entry struct {
+	key   Key
+	value []byte
+}
+
+// LRU 按照字节预算淘汰最近最少使用数据的 value 缓存，并发安全
+// 所有方法都可以在 nil 上调用，相当于关闭缓存
+type LRU struct {
+	mu       *sync.Mutex
+	capacity int64 // 最多可以使用的字节数
+	size     int64 // 当前使用的字节数
+	ll       *list.List
+	items    map[Key]*list.Element
+	files    map[uint32]map[int64]*list.Element // 每个文件中的缓存项，用于按文件失效
+
+	hits      metrics.Counter
+	misses    metrics.Counter
+	evictions metrics.Counter
+}
+
+// Stats 缓存运行指标的快照
+type Stats struct {
+	Hits      uint64 // 命中次数
+	Misses    uint64 // 未命中次数
+	Evictions uint64 // 因为超出预算被淘汰的缓存项数量
+	Bytes     int64  // 当前使用的字节数
+	Entries   int    // 当前缓存项的数量
+}
+
+// NewLRU 初始化 LRU 缓存，capacity 为字节预算
+func NewLRU(capacity int64) *LRU {
+	return &LRU{
+		mu:       new(sync.Mutex),
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[Key]*list.Element),
+		files:    make(map[uint32]map[int64]*list.Element),
+	}
+}
+
+// Get 获取缓存的 value，返回的是拷贝，调用方可以随意修改
+func (c *LRU) Get(key Key) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses.Inc()
+		return nil, false
+	}
+	c.hits.Inc()
+	c.ll.MoveToFront(elem)
+	value := elem.Value.(*entry).value
+	return append(make([]byte, 0, len(value)), value...), true
+}
+
+// Add 加入缓存，value 会被拷贝一份，超过预算的 value 不会被缓存
+func (c *LRU) Add(key Key, value []byte) {
+	if c == nil {
+		return
+	}
+	cost := int64(len(value)) + entryOverhead
+	if cost > c.capacity {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	elem := c.ll.PushFront(&entry{key: key, value: append(make([]byte, 0, len(value)), value...)})
+	c.items[key] = elem
+	offsets, ok := c.files[key.Fid]
+	if !ok {
+		offsets = make(map[int64]*list.Element)
+		c.files[key.Fid] = offsets
+	}
+	offsets[key.Offset] = elem
+	c.size += cost
+
+	for c.size > c.capacity {
+		c.removeElement(c.ll.Back())
+		c.evictions.Inc()
+	}
+}
+
+// Remove 删除一个缓存项，对应位置的数据已经失效时调用
+func (c *LRU) Remove(key Key) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// RemoveFile 删除一个数据文件中所有的缓存项，数据文件被删除或者替换时调用
+func (c *LRU) RemoveFile(fid uint32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.files[fid] {
+		c.removeElement(elem)
+	}
+}
+
+// Clear 清空缓存
+func (c *LRU) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[Key]*list.Element)
+	c.files = make(map[uint32]map[int64]*list.Element)
+	c.size = 0
+}
+
+// Stats 获取缓存的运行指标
+func (c *LRU) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits.Value(),
+		Misses:    c.misses.Value(),
+		Evictions: c.evictions.Value(),
+		Bytes:     c.size,
+		Entries:   c.ll.Len(),
+	}
+}
+
+func (c *LRU) removeElement(elem *list.Element) {
+	e := c.ll.Remove(elem).(*entry)
+	delete(c.items, e.key)
+	if offsets := c.files[e.key.Fid]; offsets != nil {
+		delete(offsets, e.key.Offset)
+		if len(offsets) == 0 {
+			delete(c.files, e.key.Fid)
+		}
+	}
+	c.size -= int64(len(e.value)) + entryOverhead
+}
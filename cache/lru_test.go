@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLRU_GetAdd(t *testing.T) {
+	c := NewLRU(3 * (entryOverhead + 10))
+
+	_, ok := c.Get(Key{Fid: 1, Offset: 0})
+	assert.False(t, ok)
+
+	c.Add(Key{Fid: 1, Offset: 0}, []byte("bitcask-a0"))
+	c.Add(Key{Fid: 1, Offset: 10}, []byte("bitcask-a1"))
+	c.Add(Key{Fid: 2, Offset: 0}, []byte("bitcask-b0"))
+	val, ok := c.Get(Key{Fid: 1, Offset: 0})
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bitcask-a0"), val)
+
+	// 修改返回值不影响缓存中的数据
+	val[0] = 'x'
+	val, _ = c.Get(Key{Fid: 1, Offset: 0})
+	assert.Equal(t, []byte("bitcask-a0"), val)
+
+	// 超出预算，淘汰最近最少使用的 (1, 10)
+	c.Add(Key{Fid: 3, Offset: 0}, []byte("bitcask-c0"))
+	_, ok = c.Get(Key{Fid: 1, Offset: 10})
+	assert.False(t, ok)
+	_, ok = c.Get(Key{Fid: 1, Offset: 0})
+	assert.True(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 3, stats.Entries)
+	assert.Equal(t, int64(3*(entryOverhead+10)), stats.Bytes)
+
+	// 超过预算的 value 不会被缓存
+	c.Add(Key{Fid: 4, Offset: 0}, make([]byte, 1024))
+	_, ok = c.Get(Key{Fid: 4, Offset: 0})
+	assert.False(t, ok)
+}
+
+func TestLRU_Remove(t *testing.T) {
+	c := NewLRU(1024 * 1024)
+	for i := 0; i < 10; i++ {
+		c.Add(Key{Fid: uint32(i % 2), Offset: int64(i)}, []byte("bitcask"))
+	}
+
+	c.Remove(Key{Fid: 0, Offset: 0})
+	_, ok := c.Get(Key{Fid: 0, Offset: 0})
+	assert.False(t, ok)
+
+	c.RemoveFile(1)
+	for i := 1; i < 10; i += 2 {
+		_, ok := c.Get(Key{Fid: 1, Offset: int64(i)})
+		assert.False(t, ok)
+	}
+	_, ok = c.Get(Key{Fid: 0, Offset: 2})
+	assert.True(t, ok)
+	assert.Equal(t, 4, c.Stats().Entries)
+
+	c.Clear()
+	assert.Equal(t, 0, c.Stats().Entries)
+	assert.Equal(t, int64(0), c.Stats().Bytes)
+
+	// nil 缓存相当于关闭缓存
+	var nilCache *LRU
+	nilCache.Add(Key{}, []byte("bitcask"))
+	_, ok = nilCache.Get(Key{})
+	assert.False(t, ok)
+}
@@ -0,0 +1,72 @@
+package data
+
+import (
+	"hash"
+	"hash/crc32"
+	"myRosedb/utils"
+)
+
+type ChecksumType = byte
+
+const (
+	// ChecksumCRC32 crc32 IEEE 校验，默认值，与旧版本的数据文件兼容
+	ChecksumCRC32 ChecksumType = iota
+
+	// ChecksumCRC32C crc32 Castagnoli 校验，在支持 SSE4.2 的 CPU 上有硬件加速
+	ChecksumCRC32C
+
+	// ChecksumXXHash64 xxHash64 校验，LogRecord 头部中只保存低 32 位，文件尾中保存完整的 64 位
+	ChecksumXXHash64
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// 流式计算校验值，Sum64 对于 crc32 类型的校验返回 32 位的值
+type checksum interface {
+	Write([]byte) (int, error)
+	Sum64() uint64
+}
+
+type crc32Checksum struct {
+	hash.Hash32
+}
+
+func (c crc32Checksum) Sum64() uint64 {
+	return uint64(c.Sum32())
+}
+
+// 根据校验类型初始化流式校验
+func newChecksum(typ ChecksumType) checksum {
+	switch typ {
+	case ChecksumCRC32C:
+		return crc32Checksum{crc32.New(castagnoliTable)}
+	case ChecksumXXHash64:
+		return utils.NewXXHash64()
+	default:
+		return crc32Checksum{crc32.NewIEEE()}
+	}
+}
+
+// 计算 LogRecord 的校验值，parts 依次为头部（不包括校验值本身）和 key/value 部分
+func recordChecksum(typ ChecksumType, parts ...[]byte) uint32 {
+	switch typ {
+	case ChecksumCRC32C:
+		var crc uint32
+		for _, p := range parts {
+			crc = crc32.Update(crc, castagnoliTable, p)
+		}
+		return crc
+	case ChecksumXXHash64:
+		x := utils.NewXXHash64()
+		for _, p := range parts {
+			_, _ = x.Write(p)
+		}
+		return uint32(x.Sum64())
+	default:
+		var crc uint32
+		for _, p := range parts {
+			crc = crc32.Update(crc, crc32.IEEETable, p)
+		}
+		return crc
+	}
+}
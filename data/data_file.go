@@ -3,54 +3,89 @@ package data
 import (
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"io"
 	"myRosedb/fio"
 	"path/filepath"
 )
 
 var (
-	ErrInvalidCRC = errors.New("invalid crc value, log record maybe corrupted")
+	ErrInvalidCRC           = errors.New("invalid crc value, log record maybe corrupted")
+	ErrFileChecksumMismatch = errors.New("data file checksum mismatch, data file maybe corrupted")
+	ErrFileFooterMissing    = errors.New("data file footer is missing, data file maybe truncated")
 )
 
 const (
 	DataFileNameSuffix    = ".data"
+	HintFileNameSuffix    = ".hint"
 	HintFileName          = "hint-index"
 	MergeFinishedFileName = "merge-finished"
+	MergeCheckpointName   = "merge-checkpoint"
 	SeqNoFileName         = "seq-no"
+	DropAllFileName       = "drop-all"
 )
 
 // 创建 数据文件 结构体
 type DataFile struct {
+	DirPath   string        // 文件所在的目录
 	FileID    uint32        // 文件id
 	WriteOff  int64         // 文件写到了哪个位置
 	IoManager fio.IOManager // IO 读写管理
+	Cipher    *Cipher       // 加解密使用，为空表示不加密
+
+	ChecksumType ChecksumType // 写入 LogRecord 以及文件尾时使用的校验类型
+
+	trackFooter bool     // 是否需要累计文件尾的信息，只有数据文件才需要
+	footerSum   checksum // 从文件开头开始累计写入数据的校验值，为空表示没有累计
+	footerOff   int64    // 已经累计校验的数据长度
+	footerCount uint64   // 已经累计的 LogRecord 数量
 }
 
 // OpenDataFile 打开新的数据文件
 func OpenDataFile(dirPath string, fileId uint32, ioType fio.FileIOType) (*DataFile, error) {
 	//具体来说，%09d中的%d是用于表示整数的占位符，而09表示将整数格式化为9位宽度，并在左侧用零进行填充（如果需要的话）。
 	//例如，假设有一个整数值为123，则使用%09d格式化后的结果为"000000123"，宽度为9位，不足的位数用零进行填充。
+	return OpenActiveDataFile(dirPath, fileId, ioType, 0)
+}
+
+// OpenActiveDataFile 打开可以写入的数据文件，MemoryMapRW 类型的 IO 会预先映射 mapSize 大小的空间
+func OpenActiveDataFile(dirPath string, fileId uint32, ioType fio.FileIOType, mapSize int64) (*DataFile, error) {
 	fileName := GetDataFileName(dirPath, fileId)
-	return newDataFile(fileName, fileId, ioType)
+	dataFile, err := newDataFile(fileName, fileId, ioType, mapSize)
+	if err != nil {
+		return nil, err
+	}
+	dataFile.DirPath = dirPath
+	dataFile.trackFooter = true
+	return dataFile, nil
 }
 
 // OpenHintFile 打开 Hint 索引文件
 func OpenHintFile(dirPath string) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, HintFileName)
-	return newDataFile(fileName, 0, fio.StandardFIO)
+	return newDataFile(fileName, 0, fio.StandardFIO, 0)
+}
+
+// OpenDataHintFile 打开单个数据文件对应的 Hint 索引文件，其中只包含 merge 时重写的记录
+func OpenDataHintFile(dirPath string, fileId uint32) (*DataFile, error) {
+	return newDataFile(GetHintFileName(dirPath, fileId), fileId, fio.StandardFIO, 0)
 }
 
 // OpenMergeFinishedFile 打开标识 merge 完成的文件
 func OpenMergeFinishedFile(dirPath string) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, MergeFinishedFileName)
-	return newDataFile(fileName, 0, fio.StandardFIO)
+	return newDataFile(fileName, 0, fio.StandardFIO, 0)
+}
+
+// OpenMergeCheckpointFile 打开记录 merge 进度的检查点文件
+func OpenMergeCheckpointFile(dirPath string) (*DataFile, error) {
+	fileName := filepath.Join(dirPath, MergeCheckpointName)
+	return newDataFile(fileName, 0, fio.StandardFIO, 0)
 }
 
 // OpenSeqNoFile 打开储存事务序列号的文件
 func OpenSeqNoFile(dirPath string) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, SeqNoFileName)
-	return newDataFile(fileName, 0, fio.StandardFIO)
+	return newDataFile(fileName, 0, fio.StandardFIO, 0)
 }
 
 // 拿到数据文件的名字
@@ -59,9 +94,14 @@ func GetDataFileName(dirPath string, fileId uint32) string {
 	// return filepath.Join(dirPath, fmt.Sprintf("#{fileId}", fileId)+DataFileNameSuffix)
 }
 
-func newDataFile(fileName string, fileId uint32, ioType fio.FileIOType) (*DataFile, error) {
+// GetHintFileName 拿到数据文件对应的 Hint 索引文件的名字
+func GetHintFileName(dirPath string, fileId uint32) string {
+	return filepath.Join(dirPath, fmt.Sprintf("%09d", fileId)+HintFileNameSuffix)
+}
+
+func newDataFile(fileName string, fileId uint32, ioType fio.FileIOType, mapSize int64) (*DataFile, error) {
 	// 初始化 IOManager 管理器接口
-	ioManager, err := fio.NewIOManager(fileName, ioType)
+	ioManager, err := fio.NewIOManager(fileName, ioType, mapSize)
 	if err != nil {
 		return nil, err
 	}
@@ -103,59 +143,126 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	if header == nil {
 		return nil, 0, io.EOF
 	}
-	// if header.crc == 0 && header.keySize == 0 && header.valueSize == 0 {
-	//		return nil, 0, io.EOF
-	//	}
-
-	// 定义 LogRecord 结构体
-	logRecord := &LogRecord{Type: header.recordType}
+	// 读到了 MemoryMapRW 预先分配但还没有写入的空间（例如写入过程中崩溃，没有截断文件）
+	if header.crc == 0 && header.keySize == 0 && header.valueSize == 0 {
+		return nil, 0, io.EOF
+	}
 
-	// 取出对应的 key 和 value 的长度
-	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
-	var recordSize = headerSize + keySize + valueSize
+	// 取出 key 和 value 部分在磁盘上的长度（加密的记录会更长一些）
+	bodySize := header.bodySize()
+	var recordSize = headerSize + bodySize
 
 	// 开始读取用户实际存储的 key/value 数据
-	if keySize > 0 || valueSize > 0 {
-		kvBuf, err := df.readNBytes(keySize+valueSize, offset+headerSize)
+	var kvBuf []byte
+	if bodySize > 0 {
+		kvBuf, err = df.readNBytes(bodySize, offset+headerSize)
 		if err != nil {
 			return nil, 0, err
 		}
-		// 解出 key 和 value
-		logRecord.Key = kvBuf[:keySize]
-		logRecord.Value = kvBuf[keySize:]
 	}
 
-	// 校验数据的有效性，用crc校验
-	// crc 前面 4 个字节不用进行校验
-	crc := getLogRecordCRC(logRecord, headerBuf[crc32.Size:headerSize])
-	// 与存储在数据文件中的crc进行比较
-	if crc != header.crc {
-		return nil, 0, ErrInvalidCRC
+	// 校验数据的有效性，用crc校验，如果是加密的记录则进行解密
+	logRecord, err := decodeLogRecordBody(header, headerBuf[:headerSize], kvBuf, df.Cipher)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return logRecord, recordSize, nil
+}
+
+// EncodeLogRecord 按照数据文件的加密和校验配置对 LogRecord 进行编码
+func (df *DataFile) EncodeLogRecord(logRecord *LogRecord) ([]byte, int64, error) {
+	return encodeLogRecord(logRecord, df.Cipher, df.ChecksumType)
+}
+
+// DecodeLogRecord 对一段完整的、已编码的 LogRecord 字节数组进行解码，进行校验，加密的记录会被解密
+// 返回解码后的 LogRecord 以及该条记录所占的字节数
+func (df *DataFile) DecodeLogRecord(buf []byte) (*LogRecord, int64, error) {
+	header, headerSize := decodeLogRecordHeader(buf)
+	if header == nil {
+		return nil, 0, io.EOF
+	}
+
+	var recordSize = headerSize + header.bodySize()
+	if int64(len(buf)) < recordSize {
+		return nil, 0, io.ErrUnexpectedEOF
 	}
 
+	logRecord, err := decodeLogRecordBody(header, buf[:headerSize], buf[headerSize:recordSize], df.Cipher)
+	if err != nil {
+		return nil, 0, err
+	}
 	return logRecord, recordSize, nil
 }
 
 func (df *DataFile) Write(buf []byte) error {
+	return df.write(buf, true)
+}
+
+// 写入字节数组，newRecord 表示是否是一条新的 LogRecord 的开头，用于累计文件尾中的记录数量
+func (df *DataFile) write(buf []byte, newRecord bool) error {
+	// 新的数据文件从第一次写入开始累计文件尾的信息
+	if df.trackFooter && df.WriteOff == 0 {
+		df.footerSum = newChecksum(df.ChecksumType)
+		df.footerOff = 0
+		df.footerCount = 0
+	}
+
 	n, err := df.IoManager.Write(buf)
 	if err != nil {
 		return err
 	}
+	if df.footerSum != nil && df.footerOff == df.WriteOff {
+		_, _ = df.footerSum.Write(buf[:n])
+		df.footerOff += int64(n)
+		if newRecord {
+			df.footerCount++
+		}
+	}
 	df.WriteOff += int64(n)
 	return nil
 }
 
 // WriteHintRecord 写入索引信息到 hint 文件中
 func (df *DataFile) WriteHintRecord(key []byte, pos *LogRecordPos) error {
+	return df.writeHintRecord(key, pos, LogRecordNormal, nil)
+}
+
+// WriteHintDeletedRecord 写入删除标记的位置到 hint 文件中，加载时从索引中删除 key
+func (df *DataFile) WriteHintDeletedRecord(key []byte, pos *LogRecordPos) error {
+	return df.writeHintRecord(key, pos, LogRecordDeleted, nil)
+}
+
+// WriteHintRecordOf 写入 logRecord 重写之后的位置到 hint 文件中，记录的类型和版本信息一同写入
+func (df *DataFile) WriteHintRecordOf(logRecord *LogRecord, key []byte, pos *LogRecordPos) error {
+	return df.writeHintRecord(key, pos, logRecord.Type, logRecord.Version)
+}
+
+func (df *DataFile) writeHintRecord(key []byte, pos *LogRecordPos, typ LogRecordType, version *RecordVersion) error {
 	record := &LogRecord{
 		Key: key,
 		// value 就是位置索引信息
-		Value: EncodeLogRecordPos(pos),
+		Value:   EncodeLogRecordPos(pos),
+		Type:    typ,
+		Version: version,
+	}
+	encRecord, _, err := df.EncodeLogRecord(record)
+	if err != nil {
+		return err
 	}
-	encRecord, _ := EncodeLogRecord(record)
 	return df.Write(encRecord)
 }
 
+// Truncate 丢弃 size 之后的数据，之后的写入从 size 开始
+func (df *DataFile) Truncate(size int64) error {
+	if err := df.IoManager.Truncate(size); err != nil {
+		return err
+	}
+	df.WriteOff = size
+	df.footerSum = nil
+	return nil
+}
+
 // 持久化 数据文件
 func (df *DataFile) Sync() error {
 	return df.IoManager.Sync()
@@ -166,11 +273,12 @@ func (df *DataFile) Close() error {
 	return df.IoManager.Close()
 }
 
-func (df *DataFile) SetIOManager(dirPath string, ioType fio.FileIOType) error {
+// SetIOManager 切换数据文件的 IO 类型，mapSize 含义与 OpenActiveDataFile 相同
+func (df *DataFile) SetIOManager(ioType fio.FileIOType, mapSize int64) error {
 	if err := df.IoManager.Close(); err != nil {
 		return err
 	}
-	ioManager, err := fio.NewIOManager(GetDataFileName(dirPath, df.FileID), ioType)
+	ioManager, err := fio.NewIOManager(GetDataFileName(df.DirPath, df.FileID), ioType, mapSize)
 	if err != nil {
 		return err
 	}
@@ -178,6 +286,11 @@ func (df *DataFile) SetIOManager(dirPath string, ioType fio.FileIOType) error {
 	return nil
 }
 
+// ReadBytes 从 offset 开始一次性读取 n 个字节，用于将多条相邻的 LogRecord 合并为一次读取
+func (df *DataFile) ReadBytes(n int64, offset int64) ([]byte, error) {
+	return df.readNBytes(n, offset)
+}
+
 // 直接在返回值中定义变量名，就可以直接赋值了
 func (df *DataFile) readNBytes(n int64, offset int64) (b []byte, err error) {
 	b = make([]byte, n)
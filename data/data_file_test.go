@@ -1,7 +1,9 @@
 package data
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"myRosedb/fio"
 	"os"
 	"testing"
@@ -119,3 +121,133 @@ func TestDataFile_ReadLogRecord(t *testing.T) {
 	assert.Equal(t, size3, readSize3)
 
 }
+
+func TestDataFile_DecodeLogRecord(t *testing.T) {
+	rec := &LogRecord{
+		Key:   []byte("name"),
+		Value: []byte("bitcask-go"),
+		Type:  LogRecordNormal,
+	}
+	buf, size := EncodeLogRecord(rec)
+	dataFile := &DataFile{}
+
+	decRec, decSize, err := dataFile.DecodeLogRecord(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, rec, decRec)
+	assert.Equal(t, size, decSize)
+
+	// 数据不完整
+	_, _, err = dataFile.DecodeLogRecord(buf[:size-1])
+	assert.NotNil(t, err)
+
+	// 数据被篡改
+	buf[size-1] = 'x'
+	_, _, err = dataFile.DecodeLogRecord(buf)
+	assert.Equal(t, ErrInvalidCRC, err)
+}
+
+func TestDataFile_WriteFooter(t *testing.T) {
+	for _, typ := range []ChecksumType{ChecksumCRC32, ChecksumCRC32C, ChecksumXXHash64} {
+		dir, _ := os.MkdirTemp("", "bitcask-go-footer")
+		dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+		assert.Nil(t, err)
+		dataFile.ChecksumType = typ
+
+		for i := 0; i < 100; i++ {
+			encRecord, _, err := dataFile.EncodeLogRecord(&LogRecord{Key: []byte("name"), Value: []byte("bitcask-go")})
+			assert.Nil(t, err)
+			err = dataFile.Write(encRecord)
+			assert.Nil(t, err)
+		}
+		err = dataFile.WriteFooter()
+		assert.Nil(t, err)
+
+		footer, err := dataFile.Verify()
+		assert.Nil(t, err)
+		assert.NotNil(t, footer)
+		assert.Equal(t, uint64(100), footer.RecordCount)
+		assert.Equal(t, typ, footer.ChecksumType)
+
+		// 重新打开的文件没有累计的状态，从磁盘上计算的文件尾与写入时一致
+		dataFile2, err := OpenDataFile(dir, 0, fio.StandardFIO)
+		assert.Nil(t, err)
+		footer2, err := dataFile2.Verify()
+		assert.Nil(t, err)
+		assert.Equal(t, footer, footer2)
+
+		// 篡改数据之后校验失败
+		_ = dataFile.Close()
+		_ = dataFile2.Close()
+		fileName := GetDataFileName(dir, 0)
+		content, err := os.ReadFile(fileName)
+		assert.Nil(t, err)
+		content[30] ^= 0xff
+		assert.Nil(t, os.WriteFile(fileName, content, 0644))
+		dataFile3, err := OpenDataFile(dir, 0, fio.StandardFIO)
+		assert.Nil(t, err)
+		_, err = dataFile3.Verify()
+		assert.Equal(t, ErrInvalidCRC, err)
+		_ = dataFile3.Close()
+
+		// 文件尾丢失之后校验失败
+		content[30] ^= 0xff
+		assert.Nil(t, os.WriteFile(fileName, content[:footer.DataSize], 0644))
+		dataFile4, err := OpenDataFile(dir, 0, fio.StandardFIO)
+		assert.Nil(t, err)
+		_, err = dataFile4.Verify()
+		assert.Equal(t, ErrFileFooterMissing, err)
+		_ = dataFile4.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestDataFile_ReadLogRecord_Versioned(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-version")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+
+	rec := &LogRecord{
+		Key:     []byte("name"),
+		Value:   []byte("bitcask-go"),
+		Version: &RecordVersion{Seq: 300, Timestamp: -5},
+	}
+	encRecord, size, err := dataFile.EncodeLogRecord(rec)
+	assert.Nil(t, err)
+	assert.Nil(t, dataFile.Write(encRecord))
+
+	// 流式写入的记录同样保存版本信息
+	streamRec := &LogRecord{Key: []byte("b"), Version: &RecordVersion{Seq: 301, Timestamp: 100}}
+	streamSize, err := dataFile.WriteStream(streamRec, bytes.NewReader([]byte("stream")), 6)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamedRecordSize(streamRec, 6), streamSize)
+
+	readRec, readSize, err := dataFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, rec, readRec)
+	assert.Equal(t, size, readSize)
+
+	readRec, _, err = dataFile.ReadLogRecord(size)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("b"), readRec.Key)
+	assert.Equal(t, []byte("stream"), readRec.Value)
+	assert.Equal(t, streamRec.Version, readRec.Version)
+
+	// value 的读取不受版本信息影响
+	reader, err := dataFile.NewValueReader(size)
+	assert.Nil(t, err)
+	value, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("stream"), value)
+
+	// hint 文件中同样可以保存版本信息
+	hintFile, err := OpenDataHintFile(dir, 0)
+	assert.Nil(t, err)
+	pos := &LogRecordPos{Fid: 0, Offset: 0, Size: uint32(size)}
+	assert.Nil(t, hintFile.WriteHintRecordOf(&LogRecord{Type: LogRecordDeleted, Version: rec.Version}, []byte("name"), pos))
+	hintRec, _, err := hintFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, LogRecordDeleted, hintRec.Type)
+	assert.Equal(t, rec.Version, hintRec.Version)
+	assert.Equal(t, pos, DecodeLogRecordPos(hintRec.Value))
+}
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrCipherNotConfigured   = errors.New("log record is encrypted but no key provider is configured")
+	ErrEncryptionKeyNotFound = errors.New("encryption key not found")
+)
+
+const (
+	// AES-GCM 标准的 nonce 长度和认证 tag 长度
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+
+	// 加密之后 key/value 部分比明文多出的字节数
+	encryptionOverhead = gcmNonceSize + gcmTagSize
+)
+
+// KeyProvider 提供加密使用的密钥，密钥长度必须为 16、24 或 32 字节（AES-128/192/256）
+type KeyProvider interface {
+	// CurrentKey 返回当前用于加密新数据的密钥 id 和密钥
+	CurrentKey() (uint32, []byte)
+
+	// Key 根据密钥 id 返回对应的密钥，用于解密旧的数据
+	Key(id uint32) ([]byte, error)
+}
+
+// StaticKeyProvider 使用一组固定密钥的 KeyProvider，id 最大的密钥用于加密
+type StaticKeyProvider struct {
+	currentID uint32
+	keys      map[uint32][]byte
+}
+
+// NewStaticKeyProvider 初始化 StaticKeyProvider，轮换密钥时加入一个 id 更大的新密钥，并保留旧的密钥用于解密
+func NewStaticKeyProvider(keys map[uint32][]byte) (*StaticKeyProvider, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption key is provided")
+	}
+	provider := &StaticKeyProvider{keys: make(map[uint32][]byte, len(keys))}
+	var first = true
+	for id, key := range keys {
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid encryption key %d: %w", id, err)
+		}
+		provider.keys[id] = key
+		if first || id > provider.currentID {
+			provider.currentID = id
+			first = false
+		}
+	}
+	return provider, nil
+}
+
+func (p *StaticKeyProvider) CurrentKey() (uint32, []byte) {
+	return p.currentID, p.keys[p.currentID]
+}
+
+func (p *StaticKeyProvider) Key(id uint32) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, ErrEncryptionKeyNotFound
+	}
+	return key, nil
+}
+
+// Cipher 使用 AES-GCM 对 LogRecord 的 key/value 部分进行加解密，并发安全
+type Cipher struct {
+	provider KeyProvider
+	mu       *sync.RWMutex
+	aeads    map[uint32]cipher.AEAD // 缓存每个密钥 id 对应的 AEAD
+}
+
+// NewCipher 根据 KeyProvider 初始化 Cipher
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{
+		provider: provider,
+		mu:       new(sync.RWMutex),
+		aeads:    make(map[uint32]cipher.AEAD),
+	}
+}
+
+// 获取当前用于加密的密钥 id 及对应的 AEAD
+func (c *Cipher) current() (uint32, cipher.AEAD, error) {
+	id, key := c.provider.CurrentKey()
+	aead, err := c.aead(id, key)
+	return id, aead, err
+}
+
+// 根据密钥 id 获取 AEAD，key 为空时从 KeyProvider 中获取
+func (c *Cipher) aead(id uint32, key []byte) (cipher.AEAD, error) {
+	c.mu.RLock()
+	aead, ok := c.aeads[id]
+	c.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+
+	if key == nil {
+		var err error
+		if key, err = c.provider.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.aeads[id] = aead
+	c.mu.Unlock()
+	return aead, nil
+}
+
+// 加密 plain，结果为 nonce + 密文 + tag，追加到 dst 之后
+// additional 为参与认证但不加密的数据（即 LogRecord 的头部）
+func seal(dst []byte, aead cipher.AEAD, plain, additional []byte) ([]byte, error) {
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	dst = append(dst, nonce...)
+	return aead.Seal(dst, nonce, plain, additional), nil
+}
+
+// 解密 seal 生成的数据
+func (c *Cipher) open(keyID uint32, payload, additional []byte) ([]byte, error) {
+	aead, err := c.aead(keyID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < encryptionOverhead {
+		return nil, ErrInvalidCRC
+	}
+	return aead.Open(nil, payload[:gcmNonceSize], payload[gcmNonceSize:], additional)
+}
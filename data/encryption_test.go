@@ -0,0 +1,77 @@
+package data
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewStaticKeyProvider(t *testing.T) {
+	_, err := NewStaticKeyProvider(nil)
+	assert.NotNil(t, err)
+
+	_, err = NewStaticKeyProvider(map[uint32][]byte{1: []byte("short")})
+	assert.NotNil(t, err)
+
+	provider, err := NewStaticKeyProvider(map[uint32][]byte{
+		1: bytes.Repeat([]byte("a"), 16),
+		3: bytes.Repeat([]byte("b"), 32),
+		2: bytes.Repeat([]byte("c"), 24),
+	})
+	assert.Nil(t, err)
+	id, key := provider.CurrentKey()
+	assert.Equal(t, uint32(3), id)
+	assert.Equal(t, bytes.Repeat([]byte("b"), 32), key)
+
+	_, err = provider.Key(4)
+	assert.Equal(t, ErrEncryptionKeyNotFound, err)
+}
+
+func TestDataFile_EncodeLogRecord_Encrypted(t *testing.T) {
+	oldProvider, err := NewStaticKeyProvider(map[uint32][]byte{1: bytes.Repeat([]byte("a"), 16)})
+	assert.Nil(t, err)
+	oldFile := &DataFile{Cipher: NewCipher(oldProvider)}
+
+	rec := &LogRecord{
+		Key:   []byte("name"),
+		Value: []byte("bitcask-go"),
+		Type:  LogRecordDeleted,
+	}
+	buf, size, err := oldFile.EncodeLogRecord(rec)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(buf)), size)
+	assert.False(t, bytes.Contains(buf, rec.Value))
+
+	decRec, decSize, err := oldFile.DecodeLogRecord(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, rec, decRec)
+	assert.Equal(t, size, decSize)
+
+	// 密钥轮换之后，旧的记录仍然可以解密
+	newProvider, err := NewStaticKeyProvider(map[uint32][]byte{
+		1: bytes.Repeat([]byte("a"), 16),
+		2: bytes.Repeat([]byte("b"), 16),
+	})
+	assert.Nil(t, err)
+	newFile := &DataFile{Cipher: NewCipher(newProvider)}
+	decRec, _, err = newFile.DecodeLogRecord(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, rec, decRec)
+
+	// 使用新的密钥加密的记录，旧的密钥无法解密
+	buf2, _, err := newFile.EncodeLogRecord(rec)
+	assert.Nil(t, err)
+	_, _, err = oldFile.DecodeLogRecord(buf2)
+	assert.Equal(t, ErrEncryptionKeyNotFound, err)
+
+	// 没有配置 Cipher
+	plainFile := &DataFile{}
+	_, _, err = plainFile.DecodeLogRecord(buf)
+	assert.Equal(t, ErrCipherNotConfigured, err)
+
+	// 没有配置 Cipher 的时候与 EncodeLogRecord 相同
+	plain, _, err := plainFile.EncodeLogRecord(rec)
+	assert.Nil(t, err)
+	expected, _ := EncodeLogRecord(rec)
+	assert.Equal(t, expected, plain)
+}
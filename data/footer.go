@@ -0,0 +1,158 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// 每次从磁盘读取多少字节计算文件的校验值
+const footerScanChunkSize = 1024 * 1024
+
+// MaxFileFooterSize 文件尾编码之后最大的长度，数据文件需要为文件尾预留这么多空间
+const MaxFileFooterSize = maxLogRecordHeaderSize + binary.MaxVarintLen64*2 + 9 + encryptionOverhead
+
+// FileFooter 数据文件转换为旧的数据文件时，在文件末尾写入的文件尾
+type FileFooter struct {
+	RecordCount  uint64       // 文件尾之前 LogRecord 的数量
+	DataSize     int64        // 文件尾之前数据的长度，即文件尾所在的偏移
+	Checksum     uint64       // [0, DataSize) 范围内数据的校验值
+	ChecksumType ChecksumType // 计算 Checksum 使用的校验类型
+}
+
+// 对文件尾进行编码，作为 LogRecordFileFooter 类型记录的 value
+// +--------------+-----------+----------+-----------+
+// | record count | data size | checksum | 校验类型   |
+// +--------------+-----------+----------+-----------+
+// |     变长      |    变长    |  8 字节   |   1 字节   |
+func encodeFileFooter(footer *FileFooter) []byte {
+	buf := make([]byte, binary.MaxVarintLen64*2+9)
+	var index = 0
+	index += binary.PutUvarint(buf[index:], footer.RecordCount)
+	index += binary.PutVarint(buf[index:], footer.DataSize)
+	binary.LittleEndian.PutUint64(buf[index:], footer.Checksum)
+	index += 8
+	buf[index] = footer.ChecksumType
+	return buf[:index+1]
+}
+
+func decodeFileFooter(buf []byte) (*FileFooter, error) {
+	footer := &FileFooter{}
+	var index = 0
+	count, n := binary.Uvarint(buf[index:])
+	if n <= 0 {
+		return nil, errors.New("invalid data file footer")
+	}
+	index += n
+	size, n := binary.Varint(buf[index:])
+	if n <= 0 || len(buf) < index+n+9 {
+		return nil, errors.New("invalid data file footer")
+	}
+	index += n
+	footer.RecordCount = count
+	footer.DataSize = size
+	footer.Checksum = binary.LittleEndian.Uint64(buf[index:])
+	footer.ChecksumType = buf[index+8]
+	return footer, nil
+}
+
+// WriteFooter 写入文件尾，在数据文件转换为旧的数据文件时调用，之后不应该再向这个文件写入数据
+func (df *DataFile) WriteFooter() error {
+	var footer *FileFooter
+	if df.footerSum != nil && df.footerOff == df.WriteOff {
+		footer = &FileFooter{
+			RecordCount:  df.footerCount,
+			DataSize:     df.WriteOff,
+			Checksum:     df.footerSum.Sum64(),
+			ChecksumType: df.ChecksumType,
+		}
+	} else {
+		// 没有累计文件尾的信息（例如启动时加载的活跃文件），从磁盘上读取数据重新计算
+		var err error
+		if footer, err = df.scanFooter(df.WriteOff, df.ChecksumType); err != nil {
+			return err
+		}
+	}
+
+	encRecord, _, err := df.EncodeLogRecord(&LogRecord{
+		Type:  LogRecordFileFooter,
+		Value: encodeFileFooter(footer),
+	})
+	if err != nil {
+		return err
+	}
+	df.trackFooter = false
+	df.footerSum = nil
+	return df.Write(encRecord)
+}
+
+// Verify 校验整个数据文件，逐条校验 LogRecord，再校验文件尾中的记录数量和整个文件的校验值
+// 只能用于已经写入文件尾的旧的数据文件，文件尾丢失或者文件末尾被截断时返回 ErrFileFooterMissing
+func (df *DataFile) Verify() (*FileFooter, error) {
+	var offset, lastOffset int64
+	var lastRecord *LogRecord
+	for {
+		logRecord, size, err := df.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		lastRecord, lastOffset = logRecord, offset
+		offset += size
+	}
+
+	if lastRecord == nil || lastRecord.Type != LogRecordFileFooter {
+		return nil, ErrFileFooterMissing
+	}
+	footer, err := decodeFileFooter(lastRecord.Value)
+	if err != nil {
+		return nil, err
+	}
+	if footer.DataSize != lastOffset {
+		return nil, ErrFileChecksumMismatch
+	}
+	actual, err := df.scanFooter(lastOffset, footer.ChecksumType)
+	if err != nil {
+		return nil, err
+	}
+	if actual.RecordCount != footer.RecordCount || actual.Checksum != footer.Checksum {
+		return nil, ErrFileChecksumMismatch
+	}
+	return footer, nil
+}
+
+// 从磁盘读取 [0, size) 范围内的数据，计算 LogRecord 的数量以及校验值
+func (df *DataFile) scanFooter(size int64, typ ChecksumType) (*FileFooter, error) {
+	var count uint64
+	var offset int64
+	for offset < size {
+		_, n, err := df.ReadLogRecord(offset)
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+		count++
+	}
+
+	sum := newChecksum(typ)
+	for offset = 0; offset < size; offset += footerScanChunkSize {
+		n := size - offset
+		if n > footerScanChunkSize {
+			n = footerScanChunkSize
+		}
+		buf, err := df.readNBytes(n, offset)
+		if err != nil {
+			return nil, err
+		}
+		_, _ = sum.Write(buf)
+	}
+
+	return &FileFooter{
+		RecordCount:  count,
+		DataSize:     size,
+		Checksum:     sum.Sum64(),
+		ChecksumType: typ,
+	}, nil
+}
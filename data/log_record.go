@@ -1,6 +1,7 @@
 package data
 
 import (
+	"crypto/cipher"
 	"encoding/binary"
 	"hash/crc32"
 )
@@ -12,6 +13,10 @@ const (
 	LogRecordNormal LogRecordType = iota
 	LogRecordDeleted
 	LogRecordTxnFinished
+	// LogRecordFileFooter 数据文件的文件尾，不是用户数据
+	LogRecordFileFooter
+	// LogRecordRangeDeleted 范围删除标记，key 为范围的起点，value 为范围的终点（不包括），value 为空表示没有终点
+	LogRecordRangeDeleted
 )
 
 // crc type keySize valueSize
@@ -19,7 +24,26 @@ const (
 // 可变编码是什么意思？
 // 头最长可能得值
 // 不是可以自动拓展吗，没有分配够长度为什么不会自动扩容，是不是append的时候超出了两倍？
-const maxLogRecordHeaderSize = binary.MaxVarintLen32*2 + 5
+// 加密的记录在头部末尾还会存储密钥 id
+const maxLogRecordHeaderSize = binary.MaxVarintLen32*3 + 5
+
+// type 字节除了 LogRecord 的类型，还存储了加密标识和校验类型
+const (
+	// 最高位标识 LogRecord 的 key/value 部分是加密过的
+	logRecordEncryptedFlag byte = 0x80
+	// 第 6、7 位存储计算校验值使用的 ChecksumType
+	logRecordChecksumMask  byte = 0x60
+	logRecordChecksumShift      = 5
+	// 第 5 位标识 value 是流式写入的，头部的校验值只覆盖头部和 key，value 之后单独存储 4 字节的校验值
+	logRecordStreamFlag byte = 0x10
+	// 第 4 位标识 key 之前存储了版本信息
+	logRecordVersionFlag byte = 0x08
+	// 低 3 位是 LogRecord 的类型
+	logRecordTypeMask byte = 0x07
+)
+
+// 流式写入的 value 之后存储的校验值长度
+const streamTrailerSize = 4
 
 // 写入到数据文件的记录
 // 之所以叫日志，是因为数据文件中的数据是追加写入的，类似日志的格式
@@ -28,14 +52,65 @@ type LogRecord struct {
 	Key   []byte
 	Value []byte
 	Type  LogRecordType
+
+	// 版本信息，为空表示没有版本信息（旧版本写入的记录）
+	Version *RecordVersion
+}
+
+// RecordVersion LogRecord 的版本信息，编码在 key 之前，与 key 一起校验和加密
+type RecordVersion struct {
+	Seq       uint64 // 写入时分配的序列号
+	Timestamp int64  // 写入的时间（UnixNano）
+}
+
+// 写入磁盘的类型字节，带有版本信息时加上版本标识
+func (lr *LogRecord) typeByte() byte {
+	if lr.Version != nil {
+		return lr.Type | logRecordVersionFlag
+	}
+	return lr.Type
+}
+
+// 写入磁盘的 key，带有版本信息时在 key 之前加上序列号和写入时间
+// +-----------+-----------+-------+
+// |   seq     | timestamp |  key  |
+// +-----------+-----------+-------+
+// | 变长（最大10）| 变长（最大10）|  变长  |
+func (lr *LogRecord) storedKey() []byte {
+	if lr.Version == nil {
+		return lr.Key
+	}
+	buf := make([]byte, binary.MaxVarintLen64*2+len(lr.Key))
+	index := binary.PutUvarint(buf, lr.Version.Seq)
+	index += binary.PutVarint(buf[index:], lr.Version.Timestamp)
+	index += copy(buf[index:], lr.Key)
+	return buf[:index]
+}
+
+// 从磁盘上的 key 中解析出版本信息和实际的 key
+func decodeVersionedKey(storedKey []byte) (*RecordVersion, []byte, error) {
+	seq, n := binary.Uvarint(storedKey)
+	if n <= 0 {
+		return nil, nil, ErrInvalidCRC
+	}
+	timestamp, m := binary.Varint(storedKey[n:])
+	if m <= 0 {
+		return nil, nil, ErrInvalidCRC
+	}
+	return &RecordVersion{Seq: seq, Timestamp: timestamp}, storedKey[n+m:], nil
 }
 
 // LogRecord 的头部信息
 type logRecordHeader struct {
 	crc        uint32        // crc校验值
 	recordType LogRecordType // 表示 logRecord 的类型
+	versioned  bool          // key 之前是否存储了版本信息
 	keySize    uint32        // key 的长度，key的最大值为3.99G
 	valueSize  uint32        // value 的长度，value最大值为3.99G
+	checksum   ChecksumType  // 计算校验值使用的校验类型
+	encrypted  bool          // key/value 部分是否加密
+	keyID      uint32        // 加密使用的密钥 id
+	streamed   bool          // value 是否是流式写入的
 }
 
 // LogRecordPos 数据内存索引，主要是描述数据在磁盘上的位置
@@ -61,15 +136,16 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
 	header := make([]byte, maxLogRecordHeaderSize)
 
 	// 第五个字节存储 Type
-	header[4] = logRecord.Type
+	header[4] = logRecord.typeByte()
 	var index = 5
+	key := logRecord.storedKey()
 
 	// 5个字节以后，存储的是 key 和 value 的长度信息
 	// 使用变长类型，节省空间
-	index += binary.PutVarint(header[index:], int64(len(logRecord.Key)))
+	index += binary.PutVarint(header[index:], int64(len(key)))
 	index += binary.PutVarint(header[index:], int64(len(logRecord.Value)))
 
-	var size = index + len(logRecord.Key) + len(logRecord.Value)
+	var size = index + len(key) + len(logRecord.Value)
 
 	// 最终要得到的编码后的 logRecordHeader + logRecord 信息
 	encBytes := make([]byte, size)
@@ -78,8 +154,8 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
 	copy(encBytes[:index], header[:index])
 	// 将 key 和 value 数据拷贝到字节数组当中
 	// 因为本来就是字节数组，所以不需要进行转化
-	copy(encBytes[index:], logRecord.Key)
-	copy(encBytes[index+len(logRecord.Key):], logRecord.Value)
+	copy(encBytes[index:], key)
+	copy(encBytes[index+len(key):], logRecord.Value)
 
 	// 使用go中自带的crc32校验方法
 	// 对整个 LogRecord 的数据进行 crc 校验
@@ -94,6 +170,59 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
 	return encBytes, int64(size)
 }
 
+// 按照加密和校验配置对 LogRecord 进行编码，c 为空时不加密
+// 加密的记录格式如下，type 字节的最高位为加密标识位，第 6、7 位为校验类型
+// +-------+--------+----------+------------+--------+-------+-------------------+-------+
+// |  crc  |  type  | key size | value size | 密钥 id | nonce | 加密后的 key/value |  tag  |
+// +-------+--------+----------+------------+--------+-------+-------------------+-------+
+// | 4 字节 | 1 字节  |   变长    |    变长     |  变长   | 12字节 |       变长         | 16字节 |
+func encodeLogRecord(logRecord *LogRecord, c *Cipher, checksumType ChecksumType) ([]byte, int64, error) {
+	if c == nil && checksumType == ChecksumCRC32 {
+		encBytes, size := EncodeLogRecord(logRecord)
+		return encBytes, size, nil
+	}
+
+	header := make([]byte, maxLogRecordHeaderSize)
+	header[4] = logRecord.typeByte() | checksumType<<logRecordChecksumShift
+	var index = 5
+	key := logRecord.storedKey()
+	index += binary.PutVarint(header[index:], int64(len(key)))
+	index += binary.PutVarint(header[index:], int64(len(logRecord.Value)))
+
+	var keyID uint32
+	var aead cipher.AEAD
+	var bodySize = len(key) + len(logRecord.Value)
+	if c != nil {
+		var err error
+		if keyID, aead, err = c.current(); err != nil {
+			return nil, 0, err
+		}
+		header[4] |= logRecordEncryptedFlag
+		index += binary.PutUvarint(header[index:], uint64(keyID))
+		bodySize += encryptionOverhead
+	}
+
+	encBytes := make([]byte, index, index+bodySize)
+	copy(encBytes, header[:index])
+	if c != nil {
+		plain := make([]byte, len(key)+len(logRecord.Value))
+		copy(plain, key)
+		copy(plain[len(key):], logRecord.Value)
+		// 头部参与认证，防止 key size 等信息被篡改
+		var err error
+		if encBytes, err = seal(encBytes, aead, plain, encBytes[4:index]); err != nil {
+			return nil, 0, err
+		}
+	} else {
+		encBytes = append(encBytes, key...)
+		encBytes = append(encBytes, logRecord.Value...)
+	}
+
+	crc := recordChecksum(checksumType, encBytes[4:])
+	binary.LittleEndian.PutUint32(encBytes[:4], crc)
+	return encBytes, int64(len(encBytes)), nil
+}
+
 // EncodeLogRecordPos 对位置进行编码（用来存入hint文件
 func EncodeLogRecordPos(pos *LogRecordPos) []byte {
 	buf := make([]byte, binary.MaxVarintLen32*2+binary.MaxVarintLen64)
@@ -126,7 +255,11 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 	header := &logRecordHeader{
 		// 反序列化
 		crc:        binary.LittleEndian.Uint32(buf[:4]),
-		recordType: buf[4],
+		recordType: buf[4] & logRecordTypeMask,
+		versioned:  buf[4]&logRecordVersionFlag != 0,
+		checksum:   (buf[4] & logRecordChecksumMask) >> logRecordChecksumShift,
+		encrypted:  buf[4]&logRecordEncryptedFlag != 0,
+		streamed:   buf[4]&logRecordStreamFlag != 0,
 	}
 
 	var index = 5
@@ -145,9 +278,108 @@ func decodeLogRecordHeader(buf []byte) (*logRecordHeader, int64) {
 	header.valueSize = uint32(valueSize)
 	index += n
 
+	// 加密的记录还需要取出密钥 id
+	if header.encrypted {
+		keyID, n := binary.Uvarint(buf[index:])
+		header.keyID = uint32(keyID)
+		index += n
+	}
+
 	return header, int64(index)
 }
 
+// 头部之后 key/value 部分在磁盘上所占的字节数
+func (h *logRecordHeader) bodySize() int64 {
+	size := int64(h.keySize) + int64(h.valueSize)
+	if h.encrypted {
+		size += encryptionOverhead
+	}
+	if h.streamed {
+		size += streamTrailerSize
+	}
+	return size
+}
+
+// 校验 crc，并在需要时解密，得到 LogRecord
+// headerBuf 为完整的头部（包括 crc），body 为头部之后的 key/value 部分
+func decodeLogRecordBody(header *logRecordHeader, headerBuf, body []byte, c *Cipher) (*LogRecord, error) {
+	if header.streamed {
+		return decodeStreamedLogRecordBody(header, headerBuf, body)
+	}
+
+	// crc 前面 4 个字节不用进行校验
+	crc := recordChecksum(header.checksum, headerBuf[crc32.Size:], body)
+	if crc != header.crc {
+		return nil, ErrInvalidCRC
+	}
+
+	if header.encrypted {
+		if c == nil {
+			return nil, ErrCipherNotConfigured
+		}
+		plain, err := c.open(header.keyID, body, headerBuf[crc32.Size:])
+		if err != nil {
+			return nil, err
+		}
+		body = plain
+	}
+
+	logRecord := &LogRecord{Type: header.recordType}
+	if len(body) > 0 {
+		logRecord.Key = body[:header.keySize]
+		logRecord.Value = body[header.keySize:]
+	}
+	return logRecord, header.decodeKey(logRecord)
+}
+
+// 带有版本信息的记录，将版本信息从 key 中分离出来
+func (h *logRecordHeader) decodeKey(logRecord *LogRecord) error {
+	if !h.versioned {
+		return nil
+	}
+	version, key, err := decodeVersionedKey(logRecord.Key)
+	if err != nil {
+		return err
+	}
+	logRecord.Version = version
+	logRecord.Key = key
+	return nil
+}
+
+// 流式写入的记录分别校验头部和 key，以及 value
+func decodeStreamedLogRecordBody(header *logRecordHeader, headerBuf, body []byte) (*LogRecord, error) {
+	key := body[:header.keySize]
+	value := body[header.keySize : int64(header.keySize)+int64(header.valueSize)]
+	if recordChecksum(header.checksum, headerBuf[crc32.Size:], key) != header.crc {
+		return nil, ErrInvalidCRC
+	}
+	trailer := binary.LittleEndian.Uint32(body[len(body)-streamTrailerSize:])
+	if recordChecksum(header.checksum, value) != trailer {
+		return nil, ErrInvalidCRC
+	}
+	logRecord := &LogRecord{Key: key, Value: value, Type: header.recordType}
+	return logRecord, header.decodeKey(logRecord)
+}
+
+// 对流式写入的记录的头部和 key 进行编码，value 以及 value 的校验值需要随后写入
+// +-------+--------+----------+------------+-------+-------+----------------+
+// |  crc  |  type  | key size | value size |  key  | value | value 的校验值   |
+// +-------+--------+----------+------------+-------+-------+----------------+
+// | 4 字节 | 1 字节  |   变长    |    变长     |  变长  |  变长  |     4 字节      |
+func encodeStreamedLogRecordHeader(logRecord *LogRecord, valueSize int64, checksumType ChecksumType) []byte {
+	key := logRecord.storedKey()
+	encBytes := make([]byte, maxLogRecordHeaderSize, maxLogRecordHeaderSize+len(key))
+	encBytes[4] = logRecord.typeByte() | logRecordStreamFlag | checksumType<<logRecordChecksumShift
+	var index = 5
+	index += binary.PutVarint(encBytes[index:], int64(len(key)))
+	index += binary.PutVarint(encBytes[index:], valueSize)
+	encBytes = append(encBytes[:index], key...)
+
+	crc := recordChecksum(checksumType, encBytes[crc32.Size:])
+	binary.LittleEndian.PutUint32(encBytes[:crc32.Size], crc)
+	return encBytes
+}
+
 // 头部都是变长的，那怎么知道头部长度是多少
 // header 只是头部的长度
 func getLogRecordCRC(lr *LogRecord, header []byte) uint32 {
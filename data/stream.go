@@ -0,0 +1,246 @@
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+var (
+	ErrValueEncrypted    = errors.New("log record is encrypted and can not be streamed")
+	ErrInvalidStreamSize = errors.New("invalid streamed value size")
+)
+
+// 流式读写时每次读写的字节数
+const streamChunkSize = 64 * 1024
+
+// WriteStream 从 r 中读取 size 个字节作为 value，分块追加写入一条 LogRecord，返回该条记录所占的字节数
+// logRecord 中的 value 不会被使用，写入的类型为 LogRecordNormal
+// 写入失败时会截断掉已经写入的部分，保证数据文件中不会留下不完整的记录
+func (df *DataFile) WriteStream(logRecord *LogRecord, r io.Reader, size int64) (int64, error) {
+	if df.Cipher != nil {
+		return 0, ErrValueEncrypted
+	}
+	header := &LogRecord{Key: logRecord.Key, Type: LogRecordNormal, Version: logRecord.Version}
+	if size < 0 || size > math.MaxUint32-maxLogRecordHeaderSize-streamTrailerSize-int64(len(header.storedKey())) {
+		return 0, ErrInvalidStreamSize
+	}
+
+	startOff := df.WriteOff
+	if err := df.write(encodeStreamedLogRecordHeader(header, size, df.ChecksumType), true); err != nil {
+		return 0, df.discard(startOff, err)
+	}
+
+	sum := newChecksum(df.ChecksumType)
+	buf := make([]byte, streamChunkSize)
+	if size < streamChunkSize {
+		buf = buf[:size]
+	}
+	for written := int64(0); written < size; {
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, df.discard(startOff, err)
+		}
+		_, _ = sum.Write(buf[:n])
+		if err := df.write(buf[:n], false); err != nil {
+			return 0, df.discard(startOff, err)
+		}
+		written += n
+	}
+
+	trailer := make([]byte, streamTrailerSize)
+	binary.LittleEndian.PutUint32(trailer, uint32(sum.Sum64()))
+	if err := df.write(trailer, false); err != nil {
+		return 0, df.discard(startOff, err)
+	}
+	return df.WriteOff - startOff, nil
+}
+
+// StreamedRecordSize 流式写入一条记录所占的字节数
+func StreamedRecordSize(logRecord *LogRecord, valueSize int64) int64 {
+	key := logRecord.storedKey()
+	buf := make([]byte, binary.MaxVarintLen64)
+	size := int64(5 + len(key) + streamTrailerSize)
+	size += int64(binary.PutVarint(buf, int64(len(key))))
+	size += int64(binary.PutVarint(buf, valueSize))
+	return size + valueSize
+}
+
+// 丢弃 offset 之后写入的数据，返回导致写入失败的错误
+func (df *DataFile) discard(offset int64, err error) error {
+	if truncErr := df.Truncate(offset); truncErr != nil {
+		return errors.Join(err, truncErr)
+	}
+	return err
+}
+
+// ValueReader 按需从数据文件中读取一条 LogRecord 的 value，读取的同时增量计算校验值
+// 读到 value 末尾时进行校验，校验失败返回 ErrInvalidCRC
+// 跳过的部分在之后读取或者读到末尾时补充计算，所以只要读到末尾就能保证整个 value 都经过了校验
+type ValueReader struct {
+	df       *DataFile
+	offset   int64    // value 在数据文件中的偏移
+	size     int64    // value 的长度
+	pos      int64    // 当前读取的位置
+	sum      checksum // value 的校验值，普通的记录还包括头部和 key
+	hashed   int64    // 已经计算校验值的 value 长度
+	expected uint32   // 期望的校验值
+	scratch  []byte   // 补充计算跳过部分的校验值时使用
+	verified bool
+	err      error // 校验的结果
+}
+
+// NewValueReader 根据 offset 处的 LogRecord 初始化 ValueReader，加密的记录返回 ErrValueEncrypted
+func (df *DataFile) NewValueReader(offset int64) (*ValueReader, error) {
+	fileSize, err := df.IoManager.Size()
+	if err != nil {
+		return nil, err
+	}
+	var headerBytes int64 = maxLogRecordHeaderSize
+	if offset+maxLogRecordHeaderSize > fileSize {
+		headerBytes = fileSize - offset
+	}
+	headerBuf, err := df.readNBytes(headerBytes, offset)
+	if err != nil {
+		return nil, err
+	}
+	header, headerSize := decodeLogRecordHeader(headerBuf)
+	if header == nil {
+		return nil, io.EOF
+	}
+	if header.encrypted {
+		return nil, ErrValueEncrypted
+	}
+	key, err := df.readNBytes(int64(header.keySize), offset+headerSize)
+	if err != nil {
+		return nil, err
+	}
+
+	vr := &ValueReader{
+		df:     df,
+		offset: offset + headerSize + int64(header.keySize),
+		size:   int64(header.valueSize),
+		sum:    newChecksum(header.checksum),
+	}
+	if header.streamed {
+		// 流式写入的记录先校验头部和 key，value 的校验值在 value 之后
+		if recordChecksum(header.checksum, headerBuf[crc32.Size:headerSize], key) != header.crc {
+			return nil, ErrInvalidCRC
+		}
+		trailer, err := df.readNBytes(streamTrailerSize, vr.offset+vr.size)
+		if err != nil {
+			return nil, err
+		}
+		vr.expected = binary.LittleEndian.Uint32(trailer)
+	} else {
+		_, _ = vr.sum.Write(headerBuf[crc32.Size:headerSize])
+		_, _ = vr.sum.Write(key)
+		vr.expected = header.crc
+	}
+	return vr, nil
+}
+
+// Size 获取 value 的长度
+func (vr *ValueReader) Size() int64 {
+	return vr.size
+}
+
+func (vr *ValueReader) Read(p []byte) (int, error) {
+	if vr.pos >= vr.size {
+		if err := vr.verify(); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > vr.size-vr.pos {
+		p = p[:vr.size-vr.pos]
+	}
+	// 先补充计算跳过的部分的校验值
+	if err := vr.hashUpTo(vr.pos); err != nil {
+		return 0, err
+	}
+
+	n, err := vr.df.IoManager.Read(p, vr.offset+vr.pos)
+	if n < len(p) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	if end := vr.pos + int64(n); vr.hashed < end {
+		_, _ = vr.sum.Write(p[vr.hashed-vr.pos : n])
+		vr.hashed = end
+	}
+	vr.pos += int64(n)
+
+	if vr.hashed == vr.size {
+		if err := vr.verify(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (vr *ValueReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = vr.pos + offset
+	case io.SeekEnd:
+		pos = vr.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	vr.pos = pos
+	return pos, nil
+}
+
+// 计算 [hashed, target) 范围内 value 的校验值
+func (vr *ValueReader) hashUpTo(target int64) error {
+	if target > vr.size {
+		target = vr.size
+	}
+	for vr.hashed < target {
+		if vr.scratch == nil {
+			vr.scratch = make([]byte, streamChunkSize)
+		}
+		n := target - vr.hashed
+		if n > streamChunkSize {
+			n = streamChunkSize
+		}
+		if _, err := vr.df.IoManager.Read(vr.scratch[:n], vr.offset+vr.hashed); err != nil {
+			return err
+		}
+		_, _ = vr.sum.Write(vr.scratch[:n])
+		vr.hashed += n
+	}
+	return nil
+}
+
+// 校验整个 value，只会进行一次
+func (vr *ValueReader) verify() error {
+	if vr.verified {
+		return vr.err
+	}
+	if err := vr.hashUpTo(vr.size); err != nil {
+		return err
+	}
+	vr.verified = true
+	if uint32(vr.sum.Sum64()) != vr.expected {
+		vr.err = ErrInvalidCRC
+	}
+	return vr.err
+}
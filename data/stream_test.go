@@ -0,0 +1,95 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"myRosedb/fio"
+	"os"
+	"testing"
+)
+
+func TestDataFile_WriteStream(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-stream")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	dataFile.ChecksumType = ChecksumCRC32C
+
+	value := bytes.Repeat([]byte("bitcask-stream"), 20000)
+	size, err := dataFile.WriteStream(&LogRecord{Key: []byte("name")}, bytes.NewReader(value), int64(len(value)))
+	assert.Nil(t, err)
+	assert.Equal(t, StreamedRecordSize(&LogRecord{Key: []byte("name")}, int64(len(value))), size)
+
+	// 读取失败时丢弃已经写入的部分
+	_, err = dataFile.WriteStream(&LogRecord{Key: []byte("name")}, bytes.NewReader(value[:100]), int64(len(value)))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, size, dataFile.WriteOff)
+
+	// 可以作为普通的 LogRecord 读取
+	logRecord, readSize, err := dataFile.ReadLogRecord(0)
+	assert.Nil(t, err)
+	assert.Equal(t, size, readSize)
+	assert.Equal(t, []byte("name"), logRecord.Key)
+	assert.Equal(t, value, logRecord.Value)
+	assert.Equal(t, LogRecordNormal, logRecord.Type)
+	_, _, err = dataFile.ReadLogRecord(readSize)
+	assert.Equal(t, io.EOF, err)
+
+	// 随机读取 value 的一部分
+	reader, err := dataFile.NewValueReader(0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(value)), reader.Size())
+	_, err = reader.Seek(100000, io.SeekStart)
+	assert.Nil(t, err)
+	buf := make([]byte, 14)
+	_, err = io.ReadFull(reader, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, value[100000:100014], buf)
+	_, err = reader.Seek(0, io.SeekStart)
+	assert.Nil(t, err)
+	all, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, value, all)
+}
+
+func TestValueReader_Corrupted(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-stream-corrupted")
+	defer os.RemoveAll(dir)
+	dataFile, err := OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+
+	// 普通写入的记录
+	encRecord, size, err := dataFile.EncodeLogRecord(&LogRecord{Key: []byte("a"), Value: bytes.Repeat([]byte("v"), 1000)})
+	assert.Nil(t, err)
+	assert.Nil(t, dataFile.Write(encRecord))
+	// 流式写入的记录
+	_, err = dataFile.WriteStream(&LogRecord{Key: []byte("b")}, bytes.NewReader(bytes.Repeat([]byte("v"), 1000)), 1000)
+	assert.Nil(t, err)
+	assert.Nil(t, dataFile.Close())
+
+	// 篡改两条记录中 value 的最后一个字节
+	fileName := GetDataFileName(dir, 0)
+	content, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	content[size-1] ^= 0xff
+	content[len(content)-streamTrailerSize-1] ^= 0xff
+	assert.Nil(t, os.WriteFile(fileName, content, 0644))
+
+	dataFile, err = OpenDataFile(dir, 0, fio.StandardFIO)
+	assert.Nil(t, err)
+	for _, offset := range []int64{0, size} {
+		reader, err := dataFile.NewValueReader(offset)
+		assert.Nil(t, err)
+		// 只读取开头的部分无法发现错误
+		buf := make([]byte, 10)
+		_, err = reader.Read(buf)
+		assert.Nil(t, err)
+		// 跳到末尾时补充计算跳过的部分
+		_, err = reader.Seek(0, io.SeekEnd)
+		assert.Nil(t, err)
+		_, err = reader.Read(buf)
+		assert.True(t, errors.Is(err, ErrInvalidCRC))
+	}
+}
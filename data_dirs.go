@@ -0,0 +1,107 @@
+package bitcask_go
+
+import (
+	"github.com/gofrs/flock"
+	"myRosedb/utils"
+	"os"
+	"path/filepath"
+)
+
+type DataDirPolicy = uint8
+
+const (
+	// DataDirRoundRobin 按照文件 id 轮流放置到 DirPath 和 DataDirs 中
+	DataDirRoundRobin DataDirPolicy = iota
+
+	// DataDirFreeSpace 放置到可用空间最多的目录中
+	DataDirFreeSpace
+)
+
+// 存放数据文件的所有目录，依次为 DirPath、DataDirs 和 ColdDirPath
+func (db *DB) dataDirs() []string {
+	dirs := db.writeDirs()
+	if db.options.ColdDirPath != "" {
+		dirs = append(dirs, db.options.ColdDirPath)
+	}
+	return dirs
+}
+
+// 可以放置新的数据文件的目录
+func (db *DB) writeDirs() []string {
+	dirs := make([]string, 0, len(db.options.DataDirs)+2)
+	dirs = append(dirs, db.options.DirPath)
+	return append(dirs, db.options.DataDirs...)
+}
+
+// 根据 DataDirPolicy 选择新的数据文件所在的目录
+func (db *DB) nextDataDir(fid uint32) string {
+	dirs := db.writeDirs()
+	if len(dirs) == 1 {
+		return dirs[0]
+	}
+
+	switch db.options.DataDirPolicy {
+	case DataDirFreeSpace:
+		var (
+			bestDir  = dirs[fid%uint32(len(dirs))]
+			bestSize uint64
+		)
+		for _, dirPath := range dirs {
+			// 获取失败的目录跳过，全部失败时退化为轮流放置
+			size, err := utils.AvailableDiskSize(dirPath)
+			if err != nil {
+				continue
+			}
+			if size > bestSize {
+				bestDir, bestSize = dirPath, size
+			}
+		}
+		return bestDir
+	default:
+		return dirs[fid%uint32(len(dirs))]
+	}
+}
+
+// 所有存放数据文件的目录的总大小
+func (db *DB) dataDirsSize() (int64, error) {
+	var total int64
+	for _, dirPath := range db.dataDirs() {
+		size, err := utils.DirSize(dirPath)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// 创建 DataDirs 和冷存储目录，并对每个目录加文件锁，防止被其他数据库实例使用
+func (db *DB) lockDataDirs() error {
+	// DirPath 在 Open 中单独加锁
+	for _, dirPath := range db.dataDirs()[1:] {
+		if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+			db.unlockDataDirs()
+			return err
+		}
+		fileLock := flock.New(filepath.Join(dirPath, fileLockName))
+		hold, err := fileLock.TryLock()
+		if err == nil && !hold {
+			err = ErrDatabaseIsUsing
+		}
+		if err != nil {
+			db.options.Logger.Error("directory lock failed", logKeyDir, dirPath, logKeyErr, err)
+			db.unlockDataDirs()
+			return err
+		}
+		db.dirLocks = append(db.dirLocks, fileLock)
+	}
+	return nil
+}
+
+// 释放 DataDirs 和冷存储目录的文件锁
+func (db *DB) unlockDataDirs() {
+	for _, fileLock := range db.dirLocks {
+		_ = fileLock.Unlock()
+	}
+	db.dirLocks = nil
+}
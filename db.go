@@ -1,10 +1,13 @@
 package bitcask_go
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gofrs/flock"
 	"io"
+	"math"
+	"myRosedb/cache"
 	"myRosedb/data"
 	"myRosedb/fio"
 	"myRosedb/index"
@@ -15,6 +18,8 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // 这个文件主要存放面相用户的操作接口
@@ -22,6 +27,15 @@ import (
 const (
 	seqNoKey     = "seq.no"
 	fileLockName = "flock"
+
+	// 单条记录中 key 和 value 的长度之和的上限，LogRecordPos 中的 Size 为 uint32，需要为头部等预留空间
+	maxRecordPayloadSize = math.MaxUint32 - 1024
+
+	// 每写入这么多字节，重新获取一次磁盘的剩余空间
+	diskCheckInterval = 4 * 1024 * 1024
+
+	// MultiGet 合并读取时每次最多读取的字节数，超过这个长度的单条记录单独读取
+	multiGetMaxReadSize = 1024 * 1024
 )
 
 // DB bitcask 存储引擎
@@ -39,6 +53,26 @@ type DB struct {
 	fileLock        *flock.Flock              // 文件锁保证多进程之间的互斥
 	bytesWrite      uint                      // 累计写了多少个字节
 	reclaimSize     int64                     // 有多少数据可以用来merge
+	freeDiskSize    uint64                    // 估算的磁盘剩余可用空间
+	diskCheckBytes  int64                     // 上一次获取磁盘剩余空间之后写入的字节数
+	metrics         *dbMetrics                // 运行指标
+	cipher          *data.Cipher              // 加解密使用，为空表示不加密
+	valueCache      *cache.LRU                // value 缓存，为空表示不开启缓存
+	isTiering       bool                      // 是否正在迁移冷数据文件
+	fileReads       sync.Map                  // 每个数据文件上一次检查冷热之后的读取次数，uint32 -> *metrics.Counter
+	tieringStop     chan struct{}             // 通知后台迁移任务退出
+	tieringDone     chan struct{}             // 后台迁移任务已经退出
+	dirLocks        []*flock.Flock            // DataDirs 和冷存储目录的文件锁
+	usageMu         *sync.Mutex               // 保护 fileUsages
+	fileUsages      map[uint32]*fileUsage     // 每个数据文件中有效数据和无效数据的字节数
+	mergeLimiter    *utils.RateLimiter        // merge 读写数据文件的限速，为空表示不限速
+	mergeProgress   *mergeProgress            // merge 的进度
+	versions        *index.VersionChain       // 每个 key 最近几个版本的位置，为空表示不记录历史版本
+	largeBatches    map[uint64]uint32         // 没有结束的 LargeBatch 的事务序列号和起始文件 id
+	txnSeqs         map[uint64]uint64         // 已经提交的 LargeBatch 的事务序列号 -> 提交时分配的序列号
+
+	indexMu          *sync.RWMutex              // 保护 secondaryIndexes
+	secondaryIndexes map[string]*secondaryIndex // 用户创建的二级索引
 }
 
 // Stat 存储引擎统计信息
@@ -50,11 +84,15 @@ type Stat struct {
 }
 
 // Open 打开存储引擎实例 bitcask
-func Open(options Options) (*DB, error) {
+func Open(options Options) (_ *DB, err error) {
 	// 对用户传入的配置项进行校验
 	if err := checkOption(options); err != nil {
 		return nil, err
 	}
+	if options.Logger == nil {
+		options.Logger = nopLogger{}
+	}
+	openStart := time.Now()
 
 	var isInitial bool
 
@@ -71,11 +109,19 @@ func Open(options Options) (*DB, error) {
 	// 获取读锁（错误），应该获取写锁（互斥锁），因为读锁多进程之间可以共享
 	hold, err := fileLock.TryLock()
 	if err != nil {
+		options.Logger.Error("directory lock failed", logKeyDir, options.DirPath, logKeyErr, err)
 		return nil, err
 	}
 	if !hold {
+		options.Logger.Error("directory lock failed", logKeyDir, options.DirPath, logKeyErr, ErrDatabaseIsUsing)
 		return nil, ErrDatabaseIsUsing
 	}
+	// 打开失败时释放文件锁，之后可以重新打开
+	defer func() {
+		if err != nil {
+			_ = fileLock.Unlock()
+		}
+	}()
 
 	entries, err := os.ReadDir(options.DirPath)
 	if err != nil {
@@ -94,7 +140,47 @@ func Open(options Options) (*DB, error) {
 		index:      index.NewIndexer(options.IndexType, options.DirPath, options.SyncWrites),
 		isInital:   isInitial,
 		fileLock:   fileLock,
+		metrics:    newDBMetrics(),
+		usageMu:    new(sync.Mutex),
+		fileUsages: make(map[uint32]*fileUsage),
+
+		mergeProgress: newMergeProgress(),
+		largeBatches:  make(map[uint64]uint32),
+		txnSeqs:       make(map[uint64]uint64),
+
+		indexMu:          new(sync.RWMutex),
+		secondaryIndexes: make(map[string]*secondaryIndex),
+	}
+	if options.ValueCacheSize > 0 {
+		db.valueCache = cache.NewLRU(options.ValueCacheSize)
+	}
+	if options.MergeRateLimit > 0 {
+		db.mergeLimiter = utils.NewRateLimiter(options.MergeRateLimit, options.MergeRateLimit)
+	}
+	if options.HistoryVersions > 0 {
+		db.versions = index.NewVersionChain(options.HistoryVersions)
+	}
+	if options.Encryption != nil {
+		db.cipher = data.NewCipher(options.Encryption.KeyProvider)
+	}
+	defer func() {
+		if err != nil {
+			db.closeDataFiles()
+			db.unlockDataDirs()
+		}
+	}()
+	if err := db.lockDataDirs(); err != nil {
+		return nil, err
+	}
+	if err := db.initColdDir(); err != nil {
+		return nil, err
+	}
+
+	// 上一次没有完成的 DropAll 需要先完成
+	if err := db.finishDropAll(); err != nil {
+		return nil, err
 	}
+
 	// 加载 merge 数据目录
 	// 有bug，报错，改为linux系统即可
 	if err := db.loadMergeFile(); err != nil {
@@ -106,9 +192,11 @@ func Open(options Options) (*DB, error) {
 		return nil, err
 	}
 
-	// 重置 IO 类型为标准文件
+	// 重置 IO 类型为配置的 IO 类型
 	if db.options.MMapAtStartup {
-		db.resetIoType()
+		if err := db.resetIoType(); err != nil {
+			return nil, err
+		}
 	}
 	// 如果索引类型为 b+ 树，则不需要加载索引，因为已经持久化到磁盘中了
 	if options.IndexType != BPlusTree {
@@ -124,11 +212,11 @@ func Open(options Options) (*DB, error) {
 		}
 	}
 
-	// 如果索引类型为 B+ 树，则取出当前事务序列号
+	// 取出保存的事务序列号，merge 丢弃的记录中的序列号只保存在这个文件中
+	if err := db.loadSeqNo(); err != nil {
+		return nil, err
+	}
 	if options.IndexType == BPlusTree {
-		if err := db.loadSeqNo(); err != nil {
-			return nil, err
-		}
 		if db.activeFile != nil {
 			size, err := db.activeFile.IoManager.Size()
 			if err != nil {
@@ -138,6 +226,23 @@ func Open(options Options) (*DB, error) {
 		}
 	}
 
+	// 重新创建配置的二级索引，二级索引只保存在内存中，需要用已有的数据回填
+	for name, extractor := range options.SecondaryIndexes {
+		if err := db.CreateIndex(name, extractor); err != nil {
+			return nil, err
+		}
+	}
+
+	// 启动后台迁移冷数据文件的任务
+	db.startTiering()
+
+	options.Logger.Info("db opened",
+		logKeyDir, options.DirPath,
+		logKeyDataFiles, len(db.fileIds),
+		logKeyKeys, db.index.Size(),
+		logKeySeqNo, db.seqNo,
+		logKeyDuration, time.Since(openStart),
+	)
 	return db, nil
 }
 
@@ -147,7 +252,9 @@ func (db *DB) Close() error {
 		if err := db.fileLock.Unlock(); err != nil {
 			panic(fmt.Sprintf("filed to unlock the directory,%v", err))
 		}
+		db.unlockDataDirs()
 	}()
+	db.stopTiering()
 	if db.activeFile == nil {
 		return nil
 	}
@@ -160,19 +267,7 @@ func (db *DB) Close() error {
 	}
 
 	// 保存当前事务序列号
-	seqNoFile, err := data.OpenSeqNoFile(db.options.DirPath)
-	if err != nil {
-		return err
-	}
-	record := &data.LogRecord{
-		Key:   []byte(seqNoKey),
-		Value: []byte(strconv.FormatUint(db.seqNo, 10)),
-	}
-	encRecord, _ := data.EncodeLogRecord(record)
-	if err := seqNoFile.Write(encRecord); err != nil {
-		return err
-	}
-	if err := seqNoFile.Sync(); err != nil {
+	if err := db.saveSeqNo(); err != nil {
 		return err
 	}
 
@@ -190,6 +285,17 @@ func (db *DB) Close() error {
 	return nil
 }
 
+// 关闭已经打开的数据文件和索引，打开数据库失败时调用
+func (db *DB) closeDataFiles() {
+	if db.activeFile != nil {
+		_ = db.activeFile.Close()
+	}
+	for _, file := range db.olderFiles {
+		_ = file.Close()
+	}
+	_ = db.index.Close()
+}
+
 // Sync 持久化数据文件
 func (db *DB) Sync() error {
 	if db.activeFile == nil {
@@ -197,7 +303,7 @@ func (db *DB) Sync() error {
 	}
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	return db.activeFile.Sync()
+	return db.syncActiveFile()
 }
 
 // Stat 返回数据库的相关信息统计
@@ -210,7 +316,7 @@ func (db *DB) Stat() *Stat {
 		dataFiles += 1
 	}
 
-	dirSize, err := utils.DirSize(db.options.DirPath)
+	dirSize, err := db.dataDirsSize()
 	if err != nil {
 		panic(fmt.Sprintf("file to get dir size : %v", err))
 	}
@@ -222,19 +328,87 @@ func (db *DB) Stat() *Stat {
 	}
 }
 
+// FileVerifyResult 单个数据文件的校验结果
+type FileVerifyResult struct {
+	Fid         uint32 // 数据文件 id
+	HasFooter   bool   // 是否有文件尾，旧的数据文件都应该有文件尾，没有时 Err 为 data.ErrFileFooterMissing
+	RecordCount uint64 // 文件尾中记录的 LogRecord 数量
+	Err         error  // 校验失败的原因，为空表示校验通过
+}
+
+// VerifyFiles 校验所有旧的数据文件，逐条校验 LogRecord，再校验文件尾中的记录数量和整个文件的校验值
+// 返回每个文件的校验结果，以及第一个校验失败的错误
+func (db *DB) VerifyFiles() ([]FileVerifyResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var fileIds []int
+	for fid := range db.olderFiles {
+		fileIds = append(fileIds, int(fid))
+	}
+	sort.Ints(fileIds)
+
+	var firstErr error
+	results := make([]FileVerifyResult, 0, len(fileIds))
+	for _, fid := range fileIds {
+		result := FileVerifyResult{Fid: uint32(fid)}
+		footer, err := db.olderFiles[uint32(fid)].Verify()
+		if err != nil {
+			result.Err = err
+			if firstErr == nil {
+				firstErr = fmt.Errorf("data file %d: %w", fid, err)
+			}
+		} else if footer != nil {
+			result.HasFooter = true
+			result.RecordCount = footer.RecordCount
+		}
+		results = append(results, result)
+	}
+	return results, firstErr
+}
+
 // Backup 备份数据库，将数据文件拷贝到新的目录中
 func (db *DB) Backup(dir string) error {
+	return db.BackupCtx(context.Background(), dir)
+}
+
+// BackupCtx 备份数据库，每拷贝一个文件前检查 ctx 是否已经被取消
+// DataDirs 和冷存储目录中的数据文件也会被拷贝到 dir 中
+func (db *DB) BackupCtx(ctx context.Context, dir string) error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	return utils.CopyDir(db.options.DirPath, dir, []string{fileLockName})
+	if err := utils.CopyDirCtx(ctx, db.options.DirPath, dir, []string{fileLockName}); err != nil {
+		return err
+	}
+	for _, dirPath := range db.options.DataDirs {
+		if err := utils.CopyDirCtx(ctx, dirPath, dir, []string{fileLockName}); err != nil {
+			return err
+		}
+	}
+	// 冷存储目录中的数据文件也拷贝到同一个目录中，备份出来的数据库不需要再配置 DataDirs 和冷存储目录
+	if db.options.ColdDirPath != "" {
+		return utils.CopyDirCtx(ctx, db.options.ColdDirPath, dir, []string{fileLockName, "*" + tieringTmpSuffix})
+	}
+	return nil
 }
 
 // 写入 Key/Value 数据，Key 不能为空
 // db 中的put和delete没有对key和seqNo进行编码，因为他是非事务的
 func (db *DB) Put(key []byte, value []byte) error {
+	_, err := db.PutWithSeq(key, value)
+	return err
+}
+
+// PutWithSeq 写入 Key/Value 数据，返回这次写入分配的序列号
+func (db *DB) PutWithSeq(key []byte, value []byte) (uint64, error) {
+	defer db.metrics.putLatency.ObserveSince(time.Now())
+
 	// 先判断 key 是否无效
 	if len(key) == 0 {
-		return ErrKeyIsEmpty
+		return 0, ErrKeyIsEmpty
+	}
+	if err := db.checkKeyValueSize(key, int64(len(value))); err != nil {
+		return 0, err
 	}
 
 	// 构造 LogRecord 结构体
@@ -244,31 +418,46 @@ func (db *DB) Put(key []byte, value []byte) error {
 		Type:  data.LogRecordNormal,
 	}
 
-	// 追加数据写入磁盘文件
-	pos, err := db.appendLogRecordWithLock(logRecord)
+	// 追加数据写入磁盘文件，在同一把锁中更新内存索引，保证索引的更新顺序与数据文件中的顺序一致
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	pos, err := db.appendLogRecord(logRecord)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// 更新内存索引
-	if oldPos := db.index.Put(key, pos); oldPos != nil {
+	oldPos := db.index.Put(key, pos)
+	if oldPos != nil {
 		db.reclaimSize += int64(oldPos.Size)
+		db.valueCache.Remove(valueCacheKey(oldPos))
 	}
+	db.addFileUsage(pos, oldPos)
+	db.versions.Add(key, pos)
+	db.indexWrite(key, value)
 
-	return nil
+	return logRecord.Version.Seq, nil
 }
 
 // Delete 根据 key 删除对应的数据（直接追加 Type 为 Delete 的logRecord
 func (db *DB) Delete(key []byte) error {
+	_, err := db.DeleteWithSeq(key)
+	return err
+}
+
+// DeleteWithSeq 根据 key 删除对应的数据，返回这次删除分配的序列号，key 不存在时不写入任何数据，返回 0
+func (db *DB) DeleteWithSeq(key []byte) (uint64, error) {
+	defer db.metrics.deleteLatency.ObserveSince(time.Now())
+
 	// 判断 key 的有效性
 	if len(key) == 0 {
-		return ErrKeyIsEmpty
+		return 0, ErrKeyIsEmpty
 	}
 
 	// 先检查 key 是否存在，如果不存在的话直接返回
 	// 从索引中拿，索引中的key是不带事务号的
 	if pos := db.index.Get(key); pos == nil {
-		return nil
+		return 0, nil
 	}
 
 	// 构造 LogRecord，标识是被删除的
@@ -276,10 +465,12 @@ func (db *DB) Delete(key []byte) error {
 		Key:  logRecordKeyWithSeq(key, nonTransactionSeqNo),
 		Type: data.LogRecordDeleted,
 	}
-	// 写入到数据文件当中
-	pos, err := db.appendLogRecordWithLock(logRecord)
+	// 写入到数据文件当中，在同一把锁中更新内存索引
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	pos, err := db.appendLogRecord(logRecord)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	db.reclaimSize += int64(pos.Size)
 
@@ -287,18 +478,34 @@ func (db *DB) Delete(key []byte) error {
 	// 为什么老师的代码只有一个返回值
 	oldPos, ok := db.index.Delete(key)
 	if !ok {
-		return ErrIndexUpdateFailed
+		return 0, ErrIndexUpdateFailed
 	}
 	if oldPos != nil {
 		db.reclaimSize += int64(oldPos.Size)
+		db.valueCache.Remove(valueCacheKey(oldPos))
 	}
-	return nil
+	db.addFileUsage(pos, oldPos)
+	db.versions.Add(key, pos)
+	db.indexDelete(key)
+	return logRecord.Version.Seq, nil
 }
 
 // Get 根据 key 读取数据
 func (db *DB) Get(key []byte) ([]byte, error) {
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	return db.GetCtx(context.Background(), key)
+}
+
+// GetCtx 根据 key 读取数据，如果 ctx 已经被取消则返回 ctx.Err()
+func (db *DB) GetCtx(ctx context.Context, key []byte) ([]byte, error) {
+	defer db.metrics.getLatency.ObserveSince(time.Now())
+
+	// 读取过程只修改 valueCache 和文件读取计数，二者都是并发安全的，只需要读锁
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	// 等待锁的过程中 ctx 可能已经被取消
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// 判断 key 的有效性
 	if len(key) == 0 {
 		return nil, ErrKeyIsEmpty
@@ -315,20 +522,148 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 	return db.getValueByPosition(logRecordPos)
 }
 
+// MultiGet 批量读取多个 key 的数据，返回的 value 和 error 与传入的 keys 一一对应
+// 所有位置信息在同一把读锁下取出，按照 (Fid, Offset) 排序后，将同一文件中相邻的记录合并为一次读取，每次最多读取 multiGetMaxReadSize 字节
+func (db *DB) MultiGet(keys [][]byte) ([][]byte, []error) {
+	defer db.metrics.getLatency.ObserveSince(time.Now())
+
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+
+	// 与 GetCtx 相同，只需要读锁
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// 先从内存索引中取出所有 key 的位置信息
+	items := make([]multiGetItem, 0, len(keys))
+	for i, key := range keys {
+		if len(key) == 0 {
+			errs[i] = ErrKeyIsEmpty
+			continue
+		}
+		logRecordPos := db.index.Get(key)
+		if logRecordPos == nil {
+			errs[i] = ErrKeyNotFound
+			continue
+		}
+		// 命中缓存的 key 不需要再从磁盘读取
+		if value, ok := db.valueCache.Get(valueCacheKey(logRecordPos)); ok {
+			values[i] = value
+			continue
+		}
+		items = append(items, multiGetItem{idx: i, pos: logRecordPos})
+	}
+
+	// 按照文件 id 和偏移量排序，让磁盘读取尽量顺序进行
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].pos.Fid != items[j].pos.Fid {
+			return items[i].pos.Fid < items[j].pos.Fid
+		}
+		return items[i].pos.Offset < items[j].pos.Offset
+	})
+
+	for start := 0; start < len(items); {
+		// 找出同一个文件中首尾相连（或者重复）的一段记录，合并之后的长度不超过 multiGetMaxReadSize
+		fid := items[start].pos.Fid
+		begin := items[start].pos.Offset
+		end := begin + int64(items[start].pos.Size)
+		next := start + 1
+		for ; next < len(items); next++ {
+			pos := items[next].pos
+			if pos.Fid != fid || pos.Offset > end {
+				break
+			}
+			if pos.Offset+int64(pos.Size) > end {
+				if pos.Offset+int64(pos.Size)-begin > multiGetMaxReadSize {
+					break
+				}
+				end = pos.Offset + int64(pos.Size)
+			}
+		}
+		db.readCoalesced(items[start:next], begin, end, values, errs)
+		start = next
+	}
+
+	return values, errs
+}
+
+// MultiGet 中待读取的一个 key，idx 为其在用户传入的 keys 中的下标
+type multiGetItem struct {
+	idx int
+	pos *data.LogRecordPos
+}
+
+// 将 [begin, end) 范围内的数据一次性读出，再依次解码出每一条记录
+// 在访问此方法前必须持有读锁
+func (db *DB) readCoalesced(items []multiGetItem, begin, end int64, values [][]byte, errs []error) {
+	setErr := func(err error) {
+		for _, item := range items {
+			errs[item.idx] = err
+		}
+	}
+
+	var dataFile *data.DataFile
+	if db.activeFile != nil && db.activeFile.FileID == items[0].pos.Fid {
+		dataFile = db.activeFile
+	} else {
+		dataFile = db.olderFiles[items[0].pos.Fid]
+	}
+	if dataFile == nil {
+		setErr(ErrDataFileNotFound)
+		return
+	}
+
+	db.recordFileRead(dataFile.FileID)
+	buf, err := dataFile.ReadBytes(end-begin, begin)
+	if err != nil {
+		setErr(err)
+		return
+	}
+
+	for _, item := range items {
+		from := item.pos.Offset - begin
+		logRecord, _, err := dataFile.DecodeLogRecord(buf[from : from+int64(item.pos.Size)])
+		if err != nil {
+			errs[item.idx] = err
+			continue
+		}
+		// 与 Get 保持一致，删除标记视为 key 不存在
+		if logRecord.Type == data.LogRecordDeleted {
+			errs[item.idx] = ErrKeyNotFound
+			continue
+		}
+		values[item.idx] = logRecord.Value
+		db.valueCache.Add(valueCacheKey(item.pos), logRecord.Value)
+	}
+}
+
 // ListKeys 获取数据库中所有的 key
 func (db *DB) ListKeys() [][]byte {
+	keys, _ := db.ListKeysCtx(context.Background())
+	return keys
+}
+
+// ListKeysCtx 获取数据库中所有的 key，每取出一个 key 前检查 ctx 是否已经被取消
+func (db *DB) ListKeysCtx(ctx context.Context) ([][]byte, error) {
 	iterator := db.index.Iterator(false)
-	keys := make([][]byte, db.index.Size())
-	var idx int
+	defer iterator.Close()
+	keys := make([][]byte, 0, db.index.Size())
 	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
-		keys[idx] = iterator.Key()
-		idx++
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		keys = append(keys, iterator.Key())
 	}
-	return keys
+	return keys, nil
 }
 
 // 获取所有的数据，并执行用户指定的操作，函数返回 false 时终止遍历
 func (db *DB) Fold(fn func(key []byte, value []byte) bool) error {
+	return db.FoldCtx(context.Background(), fn)
+}
+
+// FoldCtx 与 Fold 相同，每处理一条数据前检查 ctx 是否已经被取消
+func (db *DB) FoldCtx(ctx context.Context, fn func(key []byte, value []byte) bool) error {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -336,6 +671,9 @@ func (db *DB) Fold(fn func(key []byte, value []byte) bool) error {
 	iterator := db.index.Iterator(false)
 	defer iterator.Close()
 	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		value, err := db.getValueByPosition(iterator.Value())
 		if err != nil {
 			return err
@@ -350,6 +688,11 @@ func (db *DB) Fold(fn func(key []byte, value []byte) bool) error {
 
 // 将从索引位置获取 value 数据的方法提取出来
 func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
+	// 先从缓存中查找
+	if value, ok := db.valueCache.Get(valueCacheKey(pos)); ok {
+		return value, nil
+	}
+
 	// 根据文件 id 找到对应的数据文件
 	var dataFile *data.DataFile
 	if db.activeFile.FileID == pos.Fid {
@@ -363,6 +706,7 @@ func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
 	}
 
 	// 根据偏移量读取对应的数据
+	db.recordFileRead(pos.Fid)
 	logRecord, _, err := dataFile.ReadLogRecord(pos.Offset)
 	if err != nil {
 		return nil, err
@@ -370,13 +714,19 @@ func (db *DB) getValueByPosition(pos *data.LogRecordPos) ([]byte, error) {
 
 	// 按理来说在加载索引的时候，就已经从btree中删除掉了，所以应该找不到改key对应的value。应该不用从这里再判断一次
 	if logRecord.Type == data.LogRecordDeleted {
-		return nil, err
+		return nil, ErrKeyNotFound
 	}
 
+	db.valueCache.Add(valueCacheKey(pos), logRecord.Value)
 	return logRecord.Value, nil
 
 }
 
+// 数据在缓存中的键
+func valueCacheKey(pos *data.LogRecordPos) cache.Key {
+	return cache.Key{Fid: pos.Fid, Offset: pos.Offset}
+}
+
 // 因为在batch中Commit()调用了appenLogRecord方法，但Commit()已经加锁了，所以需要一个不加锁的appendLogRecord，就单独将加锁的方法提取出来
 func (db *DB) appendLogRecordWithLock(logRecord *data.LogRecord) (*data.LogRecordPos, error) {
 	db.mu.Lock()
@@ -398,21 +748,13 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 
 	// 对数据文件进行操作
 	// 对写入数据 logRecord 进行编码
-	encRecord, size := data.EncodeLogRecord(logRecord)
-	// 如果写入的数据已经达到活跃文件的1阈值，则关闭活跃文件，并打开新的文件
-	if db.activeFile.WriteOff+size > db.options.DataFileSize {
-		// 先持久化数据文件，保证已有的数据持久化到磁盘当中
-		if err := db.activeFile.Sync(); err != nil {
-			return nil, err
-		}
-
-		// 当前活跃文件转换为旧的数据文件
-		db.olderFiles[db.activeFile.FileID] = db.activeFile
-
-		// 打开新的数据文件
-		if err := db.setActiveDataFile(); err != nil {
-			return nil, err
-		}
+	db.stampVersion(logRecord)
+	encRecord, size, err := db.activeFile.EncodeLogRecord(logRecord)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.prepareAppend(size); err != nil {
+		return nil, err
 	}
 
 	// 正式进行写入操作
@@ -420,8 +762,52 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 	if err := db.activeFile.Write(encRecord); err != nil {
 		return nil, err
 	}
+	if err := db.finishAppend(size); err != nil {
+		return nil, err
+	}
+
+	// 构建内存索引信息并返回
+	logRecordPos := &data.LogRecordPos{db.activeFile.FileID, writeOff, uint32(size)}
+	return logRecordPos, nil
+}
+
+// 检查 key 和 value 的长度是否超过了配置的上限
+func (db *DB) checkKeyValueSize(key []byte, valueSize int64) error {
+	if db.options.MaxKeySize > 0 && len(key) > int(db.options.MaxKeySize) {
+		return ErrKeyTooLarge
+	}
+	if db.options.MaxValueSize > 0 && valueSize > int64(db.options.MaxValueSize) {
+		return ErrValueTooLarge
+	}
+	if int64(len(key))+valueSize > maxRecordPayloadSize {
+		return ErrValueTooLarge
+	}
+	return nil
+}
 
+// 写入 size 个字节之前的检查，活跃文件剩余的空间不够时转换为旧的数据文件
+// 在访问此方法前必须持有互斥锁
+func (db *DB) prepareAppend(size int64) error {
+	// 磁盘空间不足时直接拒绝写入，避免写到一半出错导致活跃文件损坏
+	if err := db.checkDiskSpace(size); err != nil {
+		return err
+	}
+	// 如果写入的数据已经达到活跃文件的1阈值，则关闭活跃文件，并打开新的文件
+	// 需要为文件尾预留空间
+	// 超过阈值的记录即使写入新的文件也放不下，如果活跃文件是空的就直接写入，这个文件只存放这一条记录，下一次写入时再转换
+	if db.activeFile.WriteOff+size+data.MaxFileFooterSize > db.options.DataFileSize && db.activeFile.WriteOff > 0 {
+		if err := db.rotateActiveFile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 写入 size 个字节之后，累计写入的字节数并根据配置进行持久化
+// 在访问此方法前必须持有互斥锁
+func (db *DB) finishAppend(size int64) error {
 	db.bytesWrite += uint(size)
+	db.metrics.bytesWritten.Add(uint64(size))
 	// 看用户是否每次进行写入后都想要进行持久化，根据用户配置决定
 	var needSync = db.options.SyncWrites
 	// 如果没有打开每次持久化，并且写入字节数持久化>0
@@ -429,18 +815,103 @@ func (db *DB) appendLogRecord(logRecord *data.LogRecord) (*data.LogRecordPos, er
 		needSync = true
 	}
 	if db.options.SyncWrites {
-		if err := db.activeFile.Sync(); err != nil {
-			return nil, err
+		if err := db.syncActiveFile(); err != nil {
+			return err
 		}
 		// 清空累计值
 		if db.bytesWrite > 0 {
 			db.bytesWrite = 0
 		}
 	}
+	return nil
+}
 
-	// 构建内存索引信息并返回
-	logRecordPos := &data.LogRecordPos{db.activeFile.FileID, writeOff, uint32(size)}
-	return logRecordPos, nil
+// 检查写入 size 个字节后，磁盘剩余空间是否仍然不低于配置的最小值
+// 为了避免每次写入都进行系统调用，只在累计写入一定字节数或者估算值不足时才重新获取
+// 在访问此方法前必须持有互斥锁
+func (db *DB) checkDiskSpace(size int64) error {
+	if db.options.MinFreeDiskSize == 0 {
+		return nil
+	}
+
+	needRefresh := db.diskCheckBytes == 0 || db.diskCheckBytes >= diskCheckInterval ||
+		db.freeDiskSize < uint64(size)+db.options.MinFreeDiskSize
+	if needRefresh {
+		dirPath := db.options.DirPath
+		if db.activeFile != nil {
+			dirPath = db.activeFile.DirPath
+		}
+		freeDiskSize, err := utils.AvailableDiskSize(dirPath)
+		if err != nil {
+			return err
+		}
+		db.freeDiskSize = freeDiskSize
+		db.diskCheckBytes = 0
+	}
+
+	if db.freeDiskSize < uint64(size)+db.options.MinFreeDiskSize {
+		return ErrNoEnoughSpaceForWrite
+	}
+	db.freeDiskSize -= uint64(size)
+	db.diskCheckBytes += size
+	return nil
+}
+
+// 持久化当前活跃文件
+// 在访问此方法前必须持有互斥锁
+func (db *DB) syncActiveFile() error {
+	return db.syncDataFile(db.activeFile)
+}
+
+// 持久化数据文件，并记录 fsync 的次数和耗时
+func (db *DB) syncDataFile(dataFile *data.DataFile) error {
+	defer db.metrics.syncLatency.ObserveSince(time.Now())
+	db.metrics.syncCount.Inc()
+	return dataFile.Sync()
+}
+
+// 将当前活跃文件转换为旧的数据文件，写入文件尾并持久化，然后使用新的活跃文件
+// 在访问此方法前必须持有互斥锁
+func (db *DB) rotateActiveFile() error {
+	oldFile := db.activeFile
+	// 先打开新的活跃文件，这样即使写完文件尾之后崩溃，重启后也不会继续向有文件尾的文件追加数据
+	if err := db.setActiveDataFile(); err != nil {
+		return err
+	}
+	db.olderFiles[oldFile.FileID] = oldFile
+
+	if err := oldFile.WriteFooter(); err != nil {
+		return err
+	}
+	// 持久化数据文件，保证已有的数据持久化到磁盘当中
+	if err := db.syncDataFile(oldFile); err != nil {
+		return err
+	}
+
+	// 旧的数据文件不再需要预先映射的空间，重新打开之后文件大小与实际写入的数据一致
+	// 旧的数据文件与活跃文件使用的 IO 类型不同时也需要重新打开
+	activeType, olderType := db.fileIOType(true), db.fileIOType(false)
+	if activeType == fio.MemoryMapRW || activeType != olderType {
+		return oldFile.SetIOManager(olderType, 0)
+	}
+	return nil
+}
+
+// 获取数据文件使用的 IO 类型，active 表示是否为活跃文件
+func (db *DB) fileIOType(active bool) fio.FileIOType {
+	if active && db.options.DirectIO&DirectIOWrites != 0 {
+		return fio.DirectFIO
+	}
+	if !active && db.options.DirectIO&DirectIOReads != 0 {
+		return fio.DirectFIO
+	}
+	return db.options.IOType
+}
+
+// 按照配置设置文件的加密和校验方式
+func (db *DB) configureFile(dataFile *data.DataFile) {
+	dataFile.Cipher = db.cipher
+	dataFile.ChecksumType = db.options.ChecksumType
 }
 
 // 设置当前活跃文件
@@ -450,43 +921,54 @@ func (db *DB) setActiveDataFile() error {
 	if db.activeFile != nil {
 		// 新的活跃文件id 在上一个之上 1
 		initialFileID = db.activeFile.FileID + 1
+		db.metrics.fileRotations.Inc()
 	}
 
-	// 打开新的数据文件
-	dataFile, err := data.OpenDataFile(db.options.DirPath, initialFileID, fio.StandardFIO)
+	// 打开新的数据文件，开启 DataDirs 时按照 DataDirPolicy 选择所在的目录
+	dirPath := db.nextDataDir(initialFileID)
+	dataFile, err := data.OpenActiveDataFile(dirPath, initialFileID, db.fileIOType(true), db.options.DataFileSize)
 	if err != nil {
 		return err
 	}
+	db.configureFile(dataFile)
+	if db.activeFile != nil && db.activeFile.DirPath != dirPath {
+		// 换到了另一个目录，需要重新获取磁盘的可用空间
+		db.diskCheckBytes = 0
+	}
+	if db.activeFile != nil {
+		db.options.Logger.Info("data file rotated",
+			logKeyDir, dirPath,
+			logKeyOldFid, db.activeFile.FileID,
+			logKeyNewFid, initialFileID,
+		)
+	}
 	db.activeFile = dataFile
 	return nil
 }
 
 // 从磁盘中加载数据文件
 func (db *DB) loadDataFiles() error {
-	// 读取目录中的所有条目
-	dirEntries, err := os.ReadDir(db.options.DirPath)
-	if err != nil {
-		return err
+	// 记录每个数据文件所在的目录
+	fileDirs := make(map[int]string)
+	for _, dirPath := range db.dataDirs() {
+		dirFileIds, err := getDataFileIds(dirPath)
+		if err != nil {
+			return err
+		}
+		for _, fid := range dirFileIds {
+			// 迁移到冷存储目录之后还没来得及删除原来的文件，冷存储目录中的文件一定是完整的，保留这一份
+			if oldDir, ok := fileDirs[fid]; ok {
+				if err := os.Remove(data.GetDataFileName(oldDir, uint32(fid))); err != nil {
+					return err
+				}
+			}
+			fileDirs[fid] = dirPath
+		}
 	}
 
 	var fileIds []int
-	// 遍历目录中的所有文件，找到所有以 .data 结尾的文件
-	for _, entry := range dirEntries {
-		if strings.HasSuffix(entry.Name(), data.DataFileNameSuffix) {
-			// 000001.data，将文件id解析
-			splitNames := strings.Split(entry.Name(), ".")
-			fmt.Println(splitNames, splitNames[0])
-			// 包strconv实现了与基本数据类型的字符串表示形式之间的转换，Atoi相当于ParseInt（s,10,0），转换为int类型。
-			// 这里乱码了，原因是写文件名的时候代码有错误
-			fileId, err := strconv.Atoi(splitNames[0])
-			// 数据目录有可能被损坏了
-			if err != nil {
-				return ErrDataDirectoryCorrupted
-			}
-
-			fileIds = append(fileIds, fileId)
-
-		}
+	for fid := range fileDirs {
+		fileIds = append(fileIds, fid)
 	}
 
 	// 对文件 id 进行排序，从小到大依次加载
@@ -495,14 +977,20 @@ func (db *DB) loadDataFiles() error {
 
 	// 遍历每个文件 id，打开对应的数据文件
 	for i, fid := range fileIds {
-		ioType := fio.StandardFIO
+		var isActive = i == len(fileIds)-1
+		ioType := db.fileIOType(isActive)
 		if db.options.MMapAtStartup {
 			ioType = fio.MemoryMap
 		}
-		dataFile, err := data.OpenDataFile(db.options.DirPath, uint32(fid), ioType)
+		var mapSize int64
+		if isActive {
+			mapSize = db.options.DataFileSize
+		}
+		dataFile, err := data.OpenActiveDataFile(fileDirs[fid], uint32(fid), ioType, mapSize)
 		if err != nil {
 			return err
 		}
+		db.configureFile(dataFile)
 		// 把最新的（id最大的）文件设置为活跃文件
 		if i == len(fileIds)-1 {
 			db.activeFile = dataFile
@@ -514,6 +1002,35 @@ func (db *DB) loadDataFiles() error {
 	return nil
 }
 
+// 获取目录中所有数据文件的 id
+func getDataFileIds(dirPath string) ([]int, error) {
+	// 读取目录中的所有条目
+	dirEntries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var fileIds []int
+	// 遍历目录中的所有文件，找到所有以 .data 结尾的文件
+	for _, entry := range dirEntries {
+		if strings.HasSuffix(entry.Name(), data.DataFileNameSuffix) {
+			// 000001.data，将文件id解析
+			splitNames := strings.Split(entry.Name(), ".")
+			// 包strconv实现了与基本数据类型的字符串表示形式之间的转换，Atoi相当于ParseInt（s,10,0），转换为int类型。
+			// 这里乱码了，原因是写文件名的时候代码有错误
+			fileId, err := strconv.Atoi(splitNames[0])
+			// 数据目录有可能被损坏了
+			if err != nil {
+				return nil, ErrDataDirectoryCorrupted
+			}
+
+			fileIds = append(fileIds, fileId)
+
+		}
+	}
+	return fileIds, nil
+}
+
 func (db *DB) loadIndexFromDataFiles() error {
 	// 没有文件，说明数据库是空的，直接返回
 	if len(db.fileIds) == 0 {
@@ -532,12 +1049,31 @@ func (db *DB) loadIndexFromDataFiles() error {
 		nonMergeFileId = fid
 	}
 
+	// 暂存事务数据
+	// uint64 是事务的id，如果判断到事务的id可以提交了，就将事务取出来，更新内存索引
+	transcationRecords := make(map[uint64][]*data.TranscationRecord)
+	var currentSeqNo = nonTransactionSeqNo
+
 	// 新定一个更新内存索引的方法，因为要重复使用
-	updateIndex := func(key []byte, typ data.LogRecordType, pos *data.LogRecordPos) {
+	// logRecord 中的 key 为实际的 key
+	updateIndex := func(logRecord *data.LogRecord, pos *data.LogRecordPos) {
+		// 记录的版本号和事务序列号使用同一个计数器
+		if logRecord.Version != nil && logRecord.Version.Seq > currentSeqNo {
+			currentSeqNo = logRecord.Version.Seq
+		}
+		// 范围删除标记删除范围内所有之前写入的 key
+		if logRecord.Type == data.LogRecordRangeDeleted {
+			db.reclaimSize += int64(pos.Size)
+			db.addFileUsage(pos, nil)
+			db.deleteRangeFromIndex(logRecord.Key, logRecord.Value, pos)
+			return
+		}
+
 		// 如果当前数据类型的type是data.LogRecordDeleted，代表它在数据库对于key有两个数据，一个原来的，一个追加的删除的
 		// 所以追加的要删除的是要merge的数据db.reclaimSize += int64(pos.Size)，原来的oldPos也是要删除的
+		key := logRecord.Key
 		var oldPos *data.LogRecordPos
-		if typ == data.LogRecordDeleted {
+		if logRecord.Type == data.LogRecordDeleted {
 			oldPos, _ = db.index.Delete(key)
 			db.reclaimSize += int64(pos.Size)
 		} else {
@@ -546,20 +1082,48 @@ func (db *DB) loadIndexFromDataFiles() error {
 		if oldPos != nil {
 			db.reclaimSize += int64(oldPos.Size)
 		}
+		db.addFileUsage(pos, oldPos)
+		db.versions.Add(key, pos)
 	}
 
-	// 暂存事务数据
-	// uint64 是事务的id，如果判断到事务的id可以提交了，就将事务取出来，更新内存索引
-	transcationRecords := make(map[uint64][]*data.TranscationRecord)
-	var currentSeqNo = nonTransactionSeqNo
+	// 事务完成，对应的 seq no 的数据可以更新到内存索引中
+	// LargeBatch 在提交时分配序列号，记录在事务完成的标识的版本信息中
+	finishTxn := func(seqNo uint64, version *data.RecordVersion, pos *data.LogRecordPos) {
+		for _, txnRecord := range transcationRecords[seqNo] {
+			updateIndex(txnRecord.Record, txnRecord.Pos)
+		}
+		delete(transcationRecords, seqNo)
+		db.addDeadBytes(pos)
+		if seqNo > currentSeqNo {
+			currentSeqNo = seqNo
+		}
+		if version != nil && version.Seq != seqNo {
+			db.txnSeqs[seqNo] = version.Seq
+			if version.Seq > currentSeqNo {
+				currentSeqNo = version.Seq
+			}
+		}
+	}
 
 	// 遍历所有的文件id，处理文件中的记录
+	var records int
 	for i, fid := range db.fileIds {
 		var fileId = uint32(fid)
 		// 如果比最近未参与 merge 的文件 id 更小，则说明已经从 Hint 文件中加载索引了
 		if hasMerge && fileId < nonMergeFileId {
 			continue
 		}
+		// merge 重写过的数据文件直接从对应的 hint 文件中加载索引，活跃文件不会被 merge
+		if i < len(db.fileIds)-1 {
+			entries, ok, err := db.loadIndexFromDataHintFile(fileId, updateIndex, finishTxn)
+			if err != nil {
+				return err
+			}
+			if ok {
+				records += entries
+				continue
+			}
+		}
 		var dataFile *data.DataFile
 		if fileId == db.activeFile.FileID {
 			dataFile = db.activeFile
@@ -576,9 +1140,22 @@ func (db *DB) loadIndexFromDataFiles() error {
 				if err == io.EOF {
 					break
 				} else {
+					db.options.Logger.Error("data file corrupted",
+						logKeyDir, dataFile.DirPath,
+						logKeyFid, fileId,
+						logKeyOffset, offset,
+						logKeyErr, err,
+					)
 					return err
 				}
 			}
+			records++
+
+			// 文件尾不是用户数据，直接跳过
+			if logRecord.Type == data.LogRecordFileFooter {
+				offset += size
+				continue
+			}
 
 			// 构造内存索引并保存
 			logRecordPos := &data.LogRecordPos{fileId, offset, uint32(size)}
@@ -588,17 +1165,17 @@ func (db *DB) loadIndexFromDataFiles() error {
 			realKey, seqNo := parseLogRecordKey(logRecord.Key)
 			if seqNo == nonTransactionSeqNo {
 				// 非事务操作，直接更新内存索引
-				updateIndex(realKey, logRecord.Type, logRecordPos)
+				logRecord.Key = realKey
+				updateIndex(logRecord, logRecordPos)
 			} else {
 				// 事务完成，对应的 seq no 的数据可以更新到内存索引中
 				if logRecord.Type == data.LogRecordTxnFinished {
-					for _, txnRecord := range transcationRecords[seqNo] {
-						updateIndex(txnRecord.Record.Key, txnRecord.Record.Type, txnRecord.Pos)
-					}
-					delete(transcationRecords, seqNo)
+					finishTxn(seqNo, logRecord.Version, logRecordPos)
 				} else {
 					// batch当中写入的数据，但是还没有判断是否提交成功，则先暂存起来
+					// 更新索引不需要 value，不保存 value 避免大批量的事务占用过多内存
 					logRecord.Key = realKey
+					logRecord.Value = nil
 					transcationRecords[seqNo] = append(transcationRecords[seqNo], &data.TranscationRecord{
 						Record: logRecord,
 						Pos:    logRecordPos,
@@ -610,6 +1187,9 @@ func (db *DB) loadIndexFromDataFiles() error {
 			if seqNo > currentSeqNo {
 				currentSeqNo = seqNo
 			}
+			if logRecord.Version != nil && logRecord.Version.Seq > currentSeqNo {
+				currentSeqNo = logRecord.Version.Seq
+			}
 
 			//if logRecord.Type == data.LogRecordDeleted {
 			//	// 都没加为什么要删？
@@ -626,13 +1206,34 @@ func (db *DB) loadIndexFromDataFiles() error {
 		}
 
 		// 如果是当前活跃文件，更新这个文件的 WriteOff
+		// 丢弃末尾没有写入数据的部分，之后从 offset 开始继续写入
 		if i == len(db.fileIds)-1 {
+			size, err := db.activeFile.IoManager.Size()
+			if err != nil {
+				return err
+			}
+			if size > offset {
+				if err := db.activeFile.Truncate(offset); err != nil {
+					return err
+				}
+			}
 			db.activeFile.WriteOff = offset
 		}
 	}
+	// 没有提交完成的事务数据都是无效数据
+	for _, txnRecords := range transcationRecords {
+		for _, txnRecord := range txnRecords {
+			db.addDeadBytes(txnRecord.Pos)
+		}
+	}
 	// 更新事务序列号
 	db.seqNo = currentSeqNo
 
+	db.options.Logger.Info("data files loaded",
+		logKeyDir, db.options.DirPath,
+		logKeyFiles, len(db.fileIds),
+		logKeyRecords, records,
+	)
 	return nil
 
 }
@@ -648,6 +1249,61 @@ func checkOption(options Options) error {
 	if options.DataFileMergeRatio < 0 || options.DataFileMergeRatio > 1 {
 		return errors.New("invalid merge ratio,must between 0 and 1")
 	}
+	if options.Encryption != nil && options.Encryption.KeyProvider == nil {
+		return errors.New("encryption key provider is empty")
+	}
+	if options.IOType != StandardIO && options.IOType != MemoryMapIO {
+		return ErrUnsupportedIOType
+	}
+	if options.ValueCacheSize < 0 {
+		return errors.New("value cache size must be greater than or equal to 0")
+	}
+	if options.DirectIO > DirectIOAll {
+		return errors.New("invalid direct io mode")
+	}
+	if int64(options.MaxKeySize) > maxRecordPayloadSize || int64(options.MaxValueSize) > maxRecordPayloadSize {
+		return errors.New("max key size and max value size must not exceed 4GB")
+	}
+	if options.MergeFileGarbageRatio < 0 || options.MergeFileGarbageRatio > 1 {
+		return errors.New("invalid merge file garbage ratio, must between 0 and 1")
+	}
+	if options.MergeRateLimit < 0 {
+		return errors.New("merge rate limit must be greater than or equal to 0")
+	}
+	if options.MergeMaxFiles < 0 {
+		return errors.New("merge max files must be greater than or equal to 0")
+	}
+	if options.HistoryVersions < 0 {
+		return errors.New("history versions must be greater than or equal to 0")
+	}
+	if options.MergeKeepVersions < 0 || options.MergeKeepVersions > options.HistoryVersions {
+		return errors.New("merge keep versions must between 0 and history versions")
+	}
+	if options.ColdDirPath != "" && options.TieringPolicy == nil {
+		return errors.New("tiering policy is empty")
+	}
+	if options.ColdDirPath != "" && filepath.Clean(options.ColdDirPath) == filepath.Clean(options.DirPath) {
+		return errors.New("cold dir path must be different from dir path")
+	}
+	dirs := map[string]bool{filepath.Clean(options.DirPath): true}
+	if options.ColdDirPath != "" {
+		dirs[filepath.Clean(options.ColdDirPath)] = true
+	}
+	for _, dirPath := range options.DataDirs {
+		if dirPath == "" {
+			return errors.New("data dir path is empty")
+		}
+		if dirs[filepath.Clean(dirPath)] {
+			return errors.New("data dirs must be different from each other and from dir path")
+		}
+		dirs[filepath.Clean(dirPath)] = true
+	}
+	if options.DataDirPolicy > DataDirFreeSpace {
+		return errors.New("invalid data dir policy")
+	}
+	if options.ChecksumType > ChecksumXXHash64 {
+		return errors.New("unsupported checksum type")
+	}
 	return nil
 }
 
@@ -661,29 +1317,92 @@ func (db *DB) loadSeqNo() error {
 	if err != nil {
 		return err
 	}
-	record, _, err := seqNoFile.ReadLogRecord(0)
-	seqNo, err := strconv.ParseUint(string(record.Value), 10, 64)
-	if err != nil {
-		return err
+	defer func() {
+		_ = seqNoFile.Close()
+	}()
+	db.configureFile(seqNoFile)
+
+	// 旧版本每次保存都追加一条记录，取其中最大的序列号，并且不能小于从数据文件中得到的序列号
+	var offset int64
+	for {
+		record, size, err := seqNoFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		seqNo, err := strconv.ParseUint(string(record.Value), 10, 64)
+		if err != nil {
+			return err
+		}
+		if seqNo > db.seqNo {
+			db.seqNo = seqNo
+		}
+		offset += size
 	}
-	db.seqNo = seqNo
 	db.seqNoFileExists = true
 	return nil
 }
 
-// 将数据文件的 IO 类型设置为标准文件 IO
+// 保存当前的事务序列号，先写入临时文件再替换，文件中只保留最新的一条记录，轮换密钥之后不会留下使用旧密钥加密的记录
+// 在访问此方法前必须持有互斥锁
+func (db *DB) saveSeqNo() error {
+	record := &data.LogRecord{
+		Key:   []byte(seqNoKey),
+		Value: []byte(strconv.FormatUint(atomic.LoadUint64(&db.seqNo), 10)),
+	}
+	encoder := &data.DataFile{}
+	db.configureFile(encoder)
+	encRecord, _, err := encoder.EncodeLogRecord(record)
+	if err != nil {
+		return err
+	}
+
+	fileName := filepath.Join(db.options.DirPath, data.SeqNoFileName)
+	tmpFile, err := os.OpenFile(fileName+tieringTmpSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fio.DataFilePerm)
+	if err != nil {
+		return err
+	}
+	if _, err := tmpFile.Write(encRecord); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fileName+tieringTmpSuffix, fileName)
+}
+
+// 将数据文件的 IO 类型设置为配置的 IO 类型
 func (db *DB) resetIoType() error {
 	if db.activeFile == nil {
 		return nil
 	}
 
-	if err := db.activeFile.SetIOManager(db.options.DirPath, fio.StandardFIO); err != nil {
+	if err := db.activeFile.SetIOManager(db.fileIOType(true), db.options.DataFileSize); err != nil {
 		return err
 	}
 	for _, dataFile := range db.olderFiles {
-		if err := dataFile.SetIOManager(db.options.DirPath, fio.StandardFIO); err != nil {
+		if err := dataFile.SetIOManager(db.fileIOType(false), 0); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// SetIOType 运行时切换所有数据文件的 IO 类型，切换期间会阻塞读写
+// 开启了 DirectIO 的数据文件仍然使用直接 IO
+func (db *DB) SetIOType(ioType IOType) error {
+	if ioType != StandardIO && ioType != MemoryMapIO {
+		return ErrUnsupportedIOType
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.options.IOType = ioType
+	return db.resetIoType()
+}
@@ -1,9 +1,14 @@
 package bitcask_go
 
 import (
+	"bytes"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"myRosedb/data"
 	"myRosedb/utils"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -44,6 +49,9 @@ func destroyDB(db *DB) {
 		if err != nil {
 			panic(err)
 		}
+		if db.options.ColdDirPath != "" {
+			_ = os.RemoveAll(db.options.ColdDirPath)
+		}
 	}
 }
 
@@ -431,3 +439,765 @@ func Test_Open2(t *testing.T) {
 	opts.DirPath = "/tmp/bitcask-go"
 
 }
+
+func TestDB_MultiGet(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-multiget")
+	opts.DirPath = dir
+	opts.DataFileSize = 4 * 1024
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	// 写入足够多的数据，让记录分布在多个数据文件中
+	for i := 0; i < 200; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i+1000))
+		assert.Nil(t, err)
+	}
+	err = db.Delete(utils.GetTestKey(10))
+	assert.Nil(t, err)
+
+	keys := [][]byte{
+		utils.GetTestKey(150),
+		utils.GetTestKey(3),
+		nil,
+		utils.GetTestKey(10),
+		utils.GetTestKey(4),
+		utils.GetTestKey(3),
+		utils.GetTestKey(999),
+	}
+	values, errs := db.MultiGet(keys)
+	assert.Equal(t, len(keys), len(values))
+	assert.Equal(t, len(keys), len(errs))
+
+	assert.Nil(t, errs[0])
+	assert.Equal(t, utils.GetTestKey(1150), values[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, utils.GetTestKey(1003), values[1])
+	assert.Equal(t, ErrKeyIsEmpty, errs[2])
+	assert.Equal(t, ErrKeyNotFound, errs[3])
+	assert.Nil(t, errs[4])
+	assert.Equal(t, utils.GetTestKey(1004), values[4])
+	assert.Nil(t, errs[5])
+	assert.Equal(t, utils.GetTestKey(1003), values[5])
+	assert.Equal(t, ErrKeyNotFound, errs[6])
+
+	// 索引指向删除标记时与 Get 一样返回 ErrKeyNotFound
+	key := utils.GetTestKey(20)
+	pos, err := db.appendLogRecordWithLock(&data.LogRecord{Key: logRecordKeyWithSeq(key, nonTransactionSeqNo), Type: data.LogRecordDeleted})
+	assert.Nil(t, err)
+	db.index.Put(key, pos)
+	_, err = db.Get(key)
+	assert.Equal(t, ErrKeyNotFound, err)
+	values, errs = db.MultiGet([][]byte{key})
+	assert.Equal(t, ErrKeyNotFound, errs[0])
+	assert.Nil(t, values[0])
+
+	// 相邻记录的总长度超过 multiGetMaxReadSize 时分多次读取
+	opts.DataFileSize = 64 * 1024 * 1024
+	dir2, _ := os.MkdirTemp("", "bitcask-go-multiget-large")
+	opts.DirPath = dir2
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	keys = nil
+	var expected [][]byte
+	for i := 0; i < 40; i++ {
+		value := utils.RandomValue(64 * 1024)
+		assert.Nil(t, db3.Put(utils.GetTestKey(i), value))
+		keys = append(keys, utils.GetTestKey(i))
+		expected = append(expected, value)
+	}
+	values, errs = db3.MultiGet(keys)
+	for i := range keys {
+		assert.Nil(t, errs[i])
+		assert.Equal(t, expected[i], values[i])
+	}
+}
+
+func TestDB_MinFreeDiskSize(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-disk-space")
+	opts.DirPath = dir
+	opts.MinFreeDiskSize = 1 << 62
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	// 剩余空间低于配置的最小值，写入被拒绝
+	err = db.Put(utils.GetTestKey(11), utils.RandomValue(20))
+	assert.Equal(t, ErrNoEnoughSpaceForWrite, err)
+	_, err = db.Get(utils.GetTestKey(11))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 恢复正常的配置后可以继续写入
+	db.options.MinFreeDiskSize = 1024
+	err = db.Put(utils.GetTestKey(11), utils.RandomValue(20))
+	assert.Nil(t, err)
+}
+
+func TestDB_Metrics(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-metrics")
+	opts.DirPath = dir
+	opts.DataFileSize = 4 * 1024
+	opts.SyncWrites = true
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(20))
+		assert.Nil(t, err)
+	}
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	err = db.Delete(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	iter := db.NewIterator(DefaultIteratorOptions)
+	iter.Close()
+
+	m := db.Metrics()
+	assert.Equal(t, uint64(100), m.PutLatency.Count)
+	assert.Equal(t, uint64(1), m.GetLatency.Count)
+	assert.Equal(t, uint64(1), m.DeleteLatency.Count)
+	assert.True(t, m.BytesWritten > 0)
+	assert.True(t, m.SyncCount >= 101)
+	assert.True(t, m.FileRotations > 0)
+	assert.Equal(t, uint64(1), m.IteratorCount)
+	assert.Equal(t, uint(99), m.KeyNum)
+
+	var buf bytes.Buffer
+	err = m.WritePrometheus(&buf)
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "bitcask_put_duration_seconds_count 100")
+	assert.Contains(t, buf.String(), "bitcask_keys 99")
+}
+
+func TestDB_Ctx(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-ctx")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 10; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(10))
+		assert.Nil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	val, err := db.GetCtx(ctx, utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.NotNil(t, val)
+	keys, err := db.ListKeysCtx(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(keys))
+
+	// Fold 过程中取消
+	var count int
+	err = db.FoldCtx(ctx, func(key []byte, value []byte) bool {
+		count++
+		if count == 5 {
+			cancel()
+		}
+		return true
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 5, count)
+
+	_, err = db.GetCtx(ctx, utils.GetTestKey(1))
+	assert.Equal(t, context.Canceled, err)
+	_, err = db.ListKeysCtx(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-ctx-backup")
+	defer os.RemoveAll(backupDir)
+	err = db.BackupCtx(ctx, backupDir)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestDB_Encryption(t *testing.T) {
+	oldProvider, err := data.NewStaticKeyProvider(map[uint32][]byte{1: bytes.Repeat([]byte("a"), 32)})
+	assert.Nil(t, err)
+
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-encryption")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.Encryption = &EncryptionOptions{KeyProvider: oldProvider}
+	db, err := Open(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i+1000))
+		assert.Nil(t, err)
+	}
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 磁盘上的数据文件中不包含明文
+	fileData, err := os.ReadFile(data.GetDataFileName(dir, 0))
+	assert.Nil(t, err)
+	assert.False(t, bytes.Contains(fileData, []byte("bitcask-go-key")))
+
+	// 轮换密钥后重启，merge 会使用新的密钥重新加密
+	newProvider, err := data.NewStaticKeyProvider(map[uint32][]byte{
+		1: bytes.Repeat([]byte("a"), 32),
+		2: bytes.Repeat([]byte("b"), 32),
+	})
+	assert.Nil(t, err)
+	opts.Encryption = &EncryptionOptions{KeyProvider: newProvider}
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	val, err := db2.Get(utils.GetTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(1010), val)
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	// 只保留新的密钥也可以读取所有数据
+	onlyNewProvider, err := data.NewStaticKeyProvider(map[uint32][]byte{2: bytes.Repeat([]byte("b"), 32)})
+	assert.Nil(t, err)
+	opts.Encryption = &EncryptionOptions{KeyProvider: onlyNewProvider}
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		val, err := db3.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i+1000), val)
+	}
+}
+
+func TestDB_VerifyFiles(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-verify")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.ChecksumType = ChecksumXXHash64
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 2000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(24))
+		assert.Nil(t, err)
+	}
+	results, err := db.VerifyFiles()
+	assert.Nil(t, err)
+	assert.True(t, len(results) > 1)
+	for _, result := range results {
+		assert.True(t, result.HasFooter)
+		assert.True(t, result.RecordCount > 0)
+	}
+
+	// 使用不同的校验类型重启，已有的数据仍然可以读取，merge 之后文件尾依然有效
+	err = db.Close()
+	assert.Nil(t, err)
+	opts.ChecksumType = ChecksumCRC32C
+	opts.DataFileMergeRatio = 0
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, int(db2.Stat().KeyNum))
+	err = db2.Merge()
+	assert.Nil(t, err)
+	_, err = db2.VerifyFiles()
+	assert.Nil(t, err)
+
+	err = db2.Close()
+	assert.Nil(t, err)
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	db2 = db3
+	for i := 0; i < 2000; i++ {
+		_, err := db3.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	_, err = db3.VerifyFiles()
+	assert.Nil(t, err)
+}
+
+func TestDB_MemoryMapIO(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-mmap-io")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	opts.IOType = MemoryMapIO
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 3000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i+3000))
+		assert.Nil(t, err)
+	}
+	val, err := db.Get(utils.GetTestKey(10))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(3010), val)
+	_, err = db.VerifyFiles()
+	assert.Nil(t, err)
+
+	// 运行时切换为标准文件 IO，再切换回来
+	err = db.SetIOType(StandardIO)
+	assert.Nil(t, err)
+	err = db.Put([]byte("name"), []byte("bitcask"))
+	assert.Nil(t, err)
+	err = db.SetIOType(MemoryMapIO)
+	assert.Nil(t, err)
+	err = db.Put([]byte("name2"), []byte("bitcask2"))
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 模拟崩溃时没有截断活跃文件，文件末尾是预先分配的空间
+	fileName := data.GetDataFileName(dir, db.activeFile.FileID)
+	stat, err := os.Stat(fileName)
+	assert.Nil(t, err)
+	err = os.Truncate(fileName, stat.Size()+4096)
+	assert.Nil(t, err)
+
+	opts.IOType = StandardIO
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	err = db2.Put([]byte("name3"), []byte("bitcask3"))
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	db2 = db3
+	for i := 0; i < 3000; i++ {
+		val, err := db3.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i+3000), val)
+	}
+	for i, key := range []string{"name", "name2", "name3"} {
+		val, err := db3.Get([]byte(key))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"bitcask", "bitcask2", "bitcask3"}[i], string(val))
+	}
+}
+
+func TestDB_DirectIO(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-direct-io")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	opts.MMapAtStartup = false
+	opts.DirectIO = DirectIOAll
+	db, err := Open(opts)
+	if err != nil {
+		t.Skipf("direct io is not supported: %v", err)
+	}
+	defer destroyDB(db)
+
+	for i := 0; i < 3000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i+3000))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 3000; i += 7 {
+		val, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i+3000), val)
+	}
+	_, err = db.VerifyFiles()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 只对旧的数据文件的读取使用直接 IO
+	opts.DirectIO = DirectIOReads
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	db = db2
+	err = db2.Put([]byte("name"), []byte("bitcask"))
+	assert.Nil(t, err)
+	for i := 0; i < 3000; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i+3000), val)
+	}
+	val, err := db2.Get([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask"), val)
+}
+
+func TestDB_ValueCache(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-value-cache")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.ValueCacheSize = 1024 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.GetTestKey(i+1000))
+		assert.Nil(t, err)
+	}
+
+	// 第一次读取未命中，之后命中缓存
+	val, err := db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	val[0] = 'x'
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GetTestKey(1001), val)
+	m := db.Metrics()
+	assert.Equal(t, uint64(1), m.ValueCache.Hits)
+	assert.Equal(t, uint64(1), m.ValueCache.Misses)
+
+	// 覆盖和删除之后读取到的是最新的数据
+	err = db.Put(utils.GetTestKey(1), []byte("new value"))
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new value"), val)
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	err = db.Delete(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	_, err = db.Get(utils.GetTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	values, errs := db.MultiGet([][]byte{utils.GetTestKey(1), utils.GetTestKey(3)})
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, []byte("new value"), values[0])
+	assert.Equal(t, utils.GetTestKey(1003), values[1])
+	assert.True(t, db.Metrics().ValueCache.Hits >= 2)
+
+	// merge 之后重启，文件 id 被 merge 之后的文件复用，读取到的仍然是正确的数据
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i + 500))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	for i := 3; i < 500; i++ {
+		val, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GetTestKey(i+1000), val)
+	}
+	for i := 500; i < 1000; i++ {
+		_, err := db2.Get(utils.GetTestKey(i))
+		assert.Equal(t, ErrKeyNotFound, err)
+	}
+}
+
+func TestDB_PutReader(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-put-reader")
+	opts.DirPath = dir
+	opts.DataFileSize = 1024 * 1024
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	value := utils.RandomValue(3 * 1024 * 1024)
+	err = db.PutReader([]byte("media"), bytes.NewReader(value), int64(len(value)))
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	err = db.PutReader([]byte("small"), strings.NewReader("bitcask"), 7)
+	assert.Nil(t, err)
+
+	val, err := db.Get([]byte("media"))
+	assert.Nil(t, err)
+	assert.Equal(t, value, val)
+
+	reader, err := db.GetReader([]byte("media"))
+	assert.Nil(t, err)
+	_, err = reader.Seek(1024*1024, io.SeekStart)
+	assert.Nil(t, err)
+	buf := make([]byte, 1024)
+	_, err = io.ReadFull(reader, buf)
+	assert.Nil(t, err)
+	assert.Equal(t, value[1024*1024:1024*1024+1024], buf)
+
+	// 普通写入的 value 也可以通过 GetReader 读取
+	reader, err = db.GetReader(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	small, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	val, err = db.Get(utils.GetTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, val, small)
+
+	_, err = db.GetReader([]byte("not exist"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// merge 并重启之后数据不变
+	err = db.Delete(utils.GetTestKey(2))
+	assert.Nil(t, err)
+	err = db.Merge()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	reader, err = db2.GetReader([]byte("media"))
+	assert.Nil(t, err)
+	all, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, value, all)
+	reader, err = db2.GetReader([]byte("small"))
+	assert.Nil(t, err)
+	all, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask"), all)
+}
+
+func TestDB_MaxKeyValueSize(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-max-size")
+	opts.DirPath = dir
+	opts.DataFileSize = 64 * 1024
+	opts.MaxKeySize = 32
+	opts.MaxValueSize = 256 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	err = db.Put(bytes.Repeat([]byte("k"), 33), []byte("v"))
+	assert.Equal(t, ErrKeyTooLarge, err)
+	err = db.Put([]byte("key"), make([]byte, 256*1024+1))
+	assert.Equal(t, ErrValueTooLarge, err)
+	err = db.PutReader([]byte("key"), bytes.NewReader(make([]byte, 256*1024+1)), 256*1024+1)
+	assert.Equal(t, ErrValueTooLarge, err)
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	err = wb.Put([]byte("key"), make([]byte, 256*1024+1))
+	assert.Equal(t, ErrValueTooLarge, err)
+
+	// 超过 DataFileSize 的记录单独写入一个数据文件
+	for i := 0; i < 10; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	large := utils.RandomValue(200 * 1024)
+	err = db.Put([]byte("large"), large)
+	assert.Nil(t, err)
+	largePos := db.index.Get([]byte("large"))
+	assert.Equal(t, int64(0), largePos.Offset)
+	err = db.Put([]byte("after"), []byte("bitcask"))
+	assert.Nil(t, err)
+	afterPos := db.index.Get([]byte("after"))
+	assert.Equal(t, largePos.Fid+1, afterPos.Fid)
+	assert.Equal(t, int64(0), afterPos.Offset)
+	assert.Equal(t, uint(3), db.Stat().DataFileNum)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	val, err := db2.Get([]byte("large"))
+	assert.Nil(t, err)
+	assert.Equal(t, large, val)
+	_, err = db2.VerifyFiles()
+	assert.Nil(t, err)
+}
+
+func TestDB_Tiering(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-tiering")
+	coldDir, _ := os.MkdirTemp("", "bitcask-go-tiering-cold")
+	opts.DirPath = dir
+	opts.ColdDirPath = coldDir
+	opts.DataFileSize = 32 * 1024
+	opts.TieringPolicy = FileAgePolicy{MinAge: 0}
+	opts.TieringInterval = 0
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 其他实例不能使用同一个冷存储目录
+	otherOpts := opts
+	otherOpts.DirPath, _ = os.MkdirTemp("", "bitcask-go-tiering-other")
+	_, err = Open(otherOpts)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+	_ = os.RemoveAll(otherOpts.DirPath)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	olderNum := len(db.olderFiles)
+	assert.True(t, olderNum > 0)
+
+	moved, err := db.MoveColdFiles()
+	assert.Nil(t, err)
+	assert.Equal(t, olderNum, moved)
+	for fid, dataFile := range db.olderFiles {
+		assert.Equal(t, coldDir, dataFile.DirPath)
+		_, err := os.Stat(data.GetDataFileName(dir, fid))
+		assert.True(t, os.IsNotExist(err))
+	}
+	// 已经迁移过的文件不会再次迁移
+	moved, err = db.MoveColdFiles()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, moved)
+	for i := 0; i < 1000; i++ {
+		_, err := db.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// 删除一半的数据之后 merge，merge 之后的文件写入热存储目录
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := db2.Get(utils.GetTestKey(i))
+		if i < 500 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+	_, err = db2.VerifyFiles()
+	assert.Nil(t, err)
+
+	// 备份出来的数据库不需要冷存储目录
+	_, err = db2.MoveColdFiles()
+	assert.Nil(t, err)
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-tiering-backup")
+	err = db2.Backup(backupDir)
+	assert.Nil(t, err)
+	backupOpts := DefaultOptions
+	backupOpts.DirPath = backupDir
+	backupOpts.DataFileSize = opts.DataFileSize
+	db3, err := Open(backupOpts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(500), db3.Stat().KeyNum)
+
+	// 两次检查之间被读取过的文件不会被迁移
+	for i := 1000; i < 2000; i++ {
+		err := db2.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	db2.options.TieringPolicy = AccessFrequencyPolicy{MinAge: 0, MaxReads: 0}
+	pos := db2.index.Get(utils.GetTestKey(1000))
+	_, err = db2.Get(utils.GetTestKey(1000))
+	assert.Nil(t, err)
+	_, err = db2.MoveColdFiles()
+	assert.Nil(t, err)
+	assert.Equal(t, dir, db2.olderFiles[pos.Fid].DirPath)
+}
+
+func TestDB_DataDirs(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-data-dirs")
+	dir1, _ := os.MkdirTemp("", "bitcask-go-data-dirs-1")
+	dir2, _ := os.MkdirTemp("", "bitcask-go-data-dirs-2")
+	defer func() {
+		_ = os.RemoveAll(dir1)
+		_ = os.RemoveAll(dir2)
+	}()
+	opts.DirPath = dir
+	opts.DataDirs = []string{dir1, dir2}
+	opts.DataFileSize = 32 * 1024
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	// 其他实例不能使用同一个目录，打开失败时释放已经获取的文件锁，之后可以重新打开
+	otherOpts := DefaultOptions
+	otherOpts.DirPath, _ = os.MkdirTemp("", "bitcask-go-data-dirs-other")
+	otherDir, _ := os.MkdirTemp("", "bitcask-go-data-dirs-other-1")
+	otherOpts.DataDirs = []string{otherDir, dir2}
+	_, err = Open(otherOpts)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+	otherOpts.DataDirs = []string{otherDir}
+	otherDB, err := Open(otherOpts)
+	assert.Nil(t, err)
+	assert.Nil(t, otherDB.Close())
+	_ = os.RemoveAll(otherOpts.DirPath)
+	_ = os.RemoveAll(otherDir)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	// 按照文件 id 轮流放置
+	dirs := []string{dir, dir1, dir2}
+	for fid, dataFile := range db.olderFiles {
+		assert.Equal(t, dirs[fid%3], dataFile.DirPath)
+		_, err := os.Stat(data.GetDataFileName(dirs[fid%3], fid))
+		assert.Nil(t, err)
+	}
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 重启之后按照文件 id 的顺序加载所有目录中的数据文件
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1000), db2.Stat().KeyNum)
+	for i := 0; i < 1000; i++ {
+		_, err := db2.Get(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 500; i++ {
+		err := db2.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	opts.DataDirPolicy = DataDirFreeSpace
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		_, err := db3.Get(utils.GetTestKey(i))
+		if i < 500 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+	for i := 1000; i < 1500; i++ {
+		err := db3.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+
+	backupDir, _ := os.MkdirTemp("", "bitcask-go-data-dirs-backup")
+	err = db3.Backup(backupDir)
+	assert.Nil(t, err)
+	backupOpts := DefaultOptions
+	backupOpts.DirPath = backupDir
+	backupOpts.DataFileSize = opts.DataFileSize
+	db4, err := Open(backupOpts)
+	defer destroyDB(db4)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1000), db4.Stat().KeyNum)
+}
@@ -0,0 +1,96 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"myRosedb/data"
+	"time"
+)
+
+// DeleteRange 删除 [start, end) 范围内的所有 key，end 为空表示删除 start 之后的所有 key
+// 只写入一条范围删除标记，重启和 merge 时会跳过标记之前写入的范围内的数据
+func (db *DB) DeleteRange(start, end []byte) error {
+	defer db.metrics.deleteLatency.ObserveSince(time.Now())
+
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return nil
+	}
+
+	// 写入标记和更新内存索引在同一把锁中完成，期间不会有其他写入
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	// 范围内没有 key 时直接返回
+	if len(db.keysInRange(start, end)) == 0 {
+		return nil
+	}
+
+	logRecord := &data.LogRecord{
+		Key:   logRecordKeyWithSeq(start, nonTransactionSeqNo),
+		Value: end,
+		Type:  data.LogRecordRangeDeleted,
+	}
+	pos, err := db.appendLogRecord(logRecord)
+	if err != nil {
+		return err
+	}
+	db.reclaimSize += int64(pos.Size)
+	db.addFileUsage(pos, nil)
+
+	db.deleteRangeFromIndex(start, end, pos)
+	return nil
+}
+
+// DeletePrefix 删除所有以 prefix 开头的 key，prefix 为空表示删除所有的 key
+func (db *DB) DeletePrefix(prefix []byte) error {
+	return db.DeleteRange(prefix, prefixEnd(prefix))
+}
+
+// 从内存索引中删除范围内在标记之前写入的 key，pos 为范围删除标记的位置
+// 与重启时一样，标记之后写入的数据不受影响
+func (db *DB) deleteRangeFromIndex(start, end []byte, pos *data.LogRecordPos) {
+	for _, key := range db.keysInRange(start, end) {
+		if keyPos := db.index.Get(key); keyPos == nil || !posBefore(keyPos, pos) {
+			continue
+		}
+		oldPos, _ := db.index.Delete(key)
+		if oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+			db.valueCache.Remove(valueCacheKey(oldPos))
+			db.addFileUsage(nil, oldPos)
+		}
+		db.versions.Add(key, pos)
+		db.indexDelete(key)
+	}
+}
+
+// 判断 a 是否在 b 之前写入，merge 和迁移之后数据文件的 id 不变
+func posBefore(a, b *data.LogRecordPos) bool {
+	return a.Fid < b.Fid || (a.Fid == b.Fid && a.Offset < b.Offset)
+}
+
+// 获取索引中 [start, end) 范围内的所有 key，end 为空表示没有终点
+// 先取出所有的 key 再关闭迭代器，B+ 树索引在迭代器关闭之前不能写入
+func (db *DB) keysInRange(start, end []byte) [][]byte {
+	var keys [][]byte
+	iterator := db.index.Iterator(false)
+	defer iterator.Close()
+	for iterator.Seek(start); iterator.Valid(); iterator.Next() {
+		if len(end) > 0 && bytes.Compare(iterator.Key(), end) >= 0 {
+			break
+		}
+		keys = append(keys, iterator.Key())
+	}
+	return keys
+}
+
+// 比所有以 prefix 开头的 key 都大的最小的 key，prefix 全部为 0xff 或者为空时返回 nil，表示没有终点
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package bitcask_go
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"myRosedb/utils"
+	"os"
+	"testing"
+)
+
+func TestDB_DeleteRange(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-delete-range")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for _, prefix := range []string{"a", "b", "c"} {
+		for i := 0; i < 100; i++ {
+			err := db.Put([]byte(fmt.Sprintf("%s%03d", prefix, i)), utils.RandomValue(128))
+			assert.Nil(t, err)
+		}
+	}
+	assert.Nil(t, db.Put([]byte{'d', 0xff}, []byte("value")))
+
+	assert.Nil(t, db.DeleteRange([]byte("a010"), []byte("a020")))
+	assert.Nil(t, db.DeletePrefix([]byte("b")))
+	assert.Nil(t, db.DeletePrefix([]byte{'d', 0xff}))
+	// 范围删除之后重新写入的数据不受影响
+	assert.Nil(t, db.Put([]byte("b050"), []byte("new value")))
+	// 范围内没有 key 时不写入标记
+	writeOff := db.activeFile.WriteOff
+	assert.Nil(t, db.DeletePrefix([]byte("e")))
+	assert.Nil(t, db.DeleteRange([]byte("c"), []byte("b")))
+	assert.Equal(t, writeOff, db.activeFile.WriteOff)
+
+	check := func(db *DB) {
+		assert.Equal(t, 100-10+1+100, db.index.Size())
+		_, err := db.Get([]byte("a010"))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = db.Get([]byte("a019"))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = db.Get([]byte("a020"))
+		assert.Nil(t, err)
+		_, err = db.Get([]byte("b000"))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = db.Get([]byte{'d', 0xff})
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err := db.Get([]byte("b050"))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new value"), val)
+	}
+	check(db)
+
+	// 重启之后同样生效
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	check(db2)
+
+	// merge 之后被范围删除的数据不会恢复
+	assert.Nil(t, db2.Merge())
+	assert.Nil(t, db2.Close())
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	check(db3)
+
+	// 只删除标记之前写入的 key
+	db3.deleteRangeFromIndex([]byte("c"), []byte("d"), db3.index.Get([]byte("c050")))
+	_, err = db3.Get([]byte("c049"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db3.Get([]byte("c050"))
+	assert.Nil(t, err)
+	assert.Equal(t, 100-10+1+50, db3.index.Size())
+
+	assert.Nil(t, db3.DeletePrefix(nil))
+	assert.Equal(t, 0, db3.index.Size())
+	assert.Nil(t, db3.Close())
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("ac"), prefixEnd([]byte("ab")))
+	assert.Equal(t, []byte("b"), prefixEnd([]byte{'a', 0xff}))
+	assert.Nil(t, prefixEnd([]byte{0xff, 0xff}))
+	assert.Nil(t, prefixEnd(nil))
+}
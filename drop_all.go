@@ -0,0 +1,133 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"myRosedb/index"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DropAll 删除数据库中的所有数据，包括数据文件、hint 文件和内存索引，序列号继续递增，不会重复使用
+// 执行期间持有写锁，完成之后数据库可以继续使用，文件锁不会被释放
+// 删除之前先写入一个标识文件，中途崩溃时下一次启动会继续删除，不会只恢复一部分数据
+// 不能与 merge、冷数据迁移或者没有结束的 LargeBatch 同时进行，执行之前创建的迭代器不能继续使用
+func (db *DB) DropAll() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.isMerging {
+		return ErrMergeIsProgress
+	}
+	if db.isTiering {
+		return ErrTieringIsProgress
+	}
+	if len(db.largeBatches) > 0 {
+		return ErrLargeBatchInProgress
+	}
+
+	// 先保存序列号，删除之后重新打开时序列号不会变小
+	if err := db.saveSeqNo(); err != nil {
+		return err
+	}
+
+	// 写入标识文件之后的删除才是安全的
+	dropFile, err := os.Create(filepath.Join(db.options.DirPath, data.DropAllFileName))
+	if err != nil {
+		return err
+	}
+	if err := dropFile.Sync(); err != nil {
+		_ = dropFile.Close()
+		return err
+	}
+	if err := dropFile.Close(); err != nil {
+		return err
+	}
+
+	// 关闭所有的数据文件
+	var fileIds []uint32
+	if db.activeFile != nil {
+		fileIds = append(fileIds, db.activeFile.FileID)
+		if err := db.activeFile.Close(); err != nil {
+			return err
+		}
+		db.activeFile = nil
+	}
+	for fid, dataFile := range db.olderFiles {
+		fileIds = append(fileIds, fid)
+		if err := dataFile.Close(); err != nil {
+			return err
+		}
+	}
+	db.olderFiles = make(map[uint32]*data.DataFile)
+	db.fileIds = nil
+
+	if err := db.removeAllFiles(); err != nil {
+		return err
+	}
+
+	// 重置内存中的状态
+	if err := db.index.Close(); err != nil {
+		return err
+	}
+	db.index = index.NewIndexer(db.options.IndexType, db.options.DirPath, db.options.SyncWrites)
+	db.txnSeqs = make(map[uint64]uint64)
+	db.reclaimSize = 0
+	db.bytesWrite = 0
+	db.diskCheckBytes = 0
+	for _, fid := range fileIds {
+		db.valueCache.RemoveFile(fid)
+		db.fileReads.Delete(fid)
+	}
+	db.usageMu.Lock()
+	db.fileUsages = make(map[uint32]*fileUsage)
+	db.usageMu.Unlock()
+	db.versions.Reset()
+	db.resetSecondaryIndexes()
+
+	db.options.Logger.Info("db dropped",
+		logKeyDir, db.options.DirPath,
+		logKeyFiles, len(fileIds),
+	)
+
+	// 重新打开一个空的活跃文件
+	return db.setActiveDataFile()
+}
+
+// 删除所有目录中的数据文件、hint 文件以及 merge 目录，最后删除 DropAll 的标识文件，事务序列号文件保留
+func (db *DB) removeAllFiles() error {
+	if err := os.RemoveAll(db.getMergePath()); err != nil {
+		return err
+	}
+	for _, dirPath := range db.dataDirs() {
+		entries, err := os.ReadDir(dirPath)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasSuffix(name, data.DataFileNameSuffix) &&
+				!strings.HasSuffix(name, data.HintFileNameSuffix) &&
+				!strings.HasSuffix(name, tieringTmpSuffix) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dirPath, name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fileName := range []string{data.HintFileName, data.MergeFinishedFileName} {
+		if err := os.Remove(filepath.Join(db.options.DirPath, fileName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Remove(filepath.Join(db.options.DirPath, data.DropAllFileName))
+}
+
+// 上一次 DropAll 没有完成时，在加载数据文件之前继续删除
+func (db *DB) finishDropAll() error {
+	if _, err := os.Stat(filepath.Join(db.options.DirPath, data.DropAllFileName)); os.IsNotExist(err) {
+		return nil
+	}
+	db.options.Logger.Warn("unfinished drop all resumed", logKeyDir, db.options.DirPath)
+	return db.removeAllFiles()
+}
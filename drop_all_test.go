@@ -0,0 +1,89 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"myRosedb/data"
+	"myRosedb/utils"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_DropAll(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-drop-all")
+	dir1, _ := os.MkdirTemp("", "bitcask-go-drop-all-1")
+	defer func() {
+		_ = os.RemoveAll(dir1)
+	}()
+	opts.DirPath = dir
+	opts.DataDirs = []string{dir1}
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.HistoryVersions = 2
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	// merge 之后重启，数据目录中会有 hint 文件
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	defer func() {
+		destroyDB(db)
+	}()
+	assert.Nil(t, err)
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put([]byte("txn"), []byte("value")))
+	seq, err := wb.CommitWithSeq()
+	assert.Nil(t, err)
+
+	// 序列号不会重置
+	assert.Nil(t, db.DropAll())
+	assert.Equal(t, 0, db.index.Size())
+	assert.Equal(t, seq, db.LatestSeq())
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	versions, err := db.History(utils.GetTestKey(1), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(versions))
+	// 只剩下新的活跃文件
+	for _, dirPath := range []string{dir, dir1} {
+		entries, err := os.ReadDir(dirPath)
+		assert.Nil(t, err)
+		for _, entry := range entries {
+			if entry.Name() != fileLockName && entry.Name() != data.SeqNoFileName && entry.Name() != data.GetDataFileName("", 0) {
+				t.Errorf("unexpected file %s after drop all", entry.Name())
+			}
+		}
+	}
+	_, err = os.Stat(db.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+
+	// 之后可以继续使用
+	seq, err = db.PutWithSeq([]byte("name"), []byte("bitcask"))
+	assert.Nil(t, err)
+	assert.Equal(t, db.LatestSeq(), seq)
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, db.index.Size())
+	assert.Equal(t, seq, db.LatestSeq())
+	val, err := db.Get([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask"), val)
+	assert.Nil(t, db.Close())
+
+	// 模拟删除到一半时崩溃，重启之后继续删除
+	err = os.WriteFile(filepath.Join(dir, data.DropAllFileName), nil, 0644)
+	assert.Nil(t, err)
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, db.index.Size())
+	assert.Equal(t, seq, db.LatestSeq())
+	_, err = os.Stat(filepath.Join(dir, data.DropAllFileName))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -9,8 +9,20 @@ var (
 	ErrDataFileNotFound       = errors.New("data file is not found")
 	ErrDataDirectoryCorrupted = errors.New("the database directory maybe corrupted")
 	ErrExceedMaxBatchNum      = errors.New("exceed the max batch num")
+	ErrExceedMaxBatchBytes    = errors.New("exceed the max batch bytes")
+	ErrLargeBatchFinished     = errors.New("the large batch is already committed or rolled back")
+	ErrLargeBatchInProgress   = errors.New("large batches are in progress, try again later")
 	ErrMergeIsProgress        = errors.New(("merge is in progree, try again later"))
 	ErrDatabaseIsUsing        = errors.New("the database directory is used by another process")
 	ErrMergeRatioUnreached    = errors.New("the merge ratio do not reach the option")
 	ErrNoEnoughSpaceForMerge  = errors.New("no enough disk space for merge")
+	ErrNoEnoughSpaceForWrite  = errors.New("no enough disk space for write, free space is below the option")
+	ErrUnsupportedIOType      = errors.New("unsupported io type")
+	ErrTieringIsProgress      = errors.New("moving cold data files is in progress, try again later")
+	ErrKeyTooLarge            = errors.New("the key exceeds the max key size")
+	ErrValueTooLarge          = errors.New("the value exceeds the max value size")
+	ErrHistoryNotEnabled      = errors.New("history versions are not enabled in the options")
+	ErrInvalidIndex           = errors.New("the secondary index name or extractor is empty")
+	ErrIndexExists            = errors.New("the secondary index already exists")
+	ErrIndexNotFound          = errors.New("the secondary index is not found")
 )
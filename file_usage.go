@@ -0,0 +1,111 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"myRosedb/metrics"
+	"os"
+	"sort"
+)
+
+// 数据文件中有效数据和无效数据的字节数，用于选择需要 merge 的数据文件
+// 删除标记在更早的数据文件被 merge 之前仍然需要保留，按照有效数据统计
+type fileUsage struct {
+	live int64
+	dead int64
+}
+
+// 无效数据的比例
+func (u fileUsage) garbageRatio() float64 {
+	if u.live+u.dead == 0 {
+		return 0
+	}
+	return float64(u.dead) / float64(u.live+u.dead)
+}
+
+// 记录新写入的数据 pos，以及被覆盖或者删除之后变为无效的数据 oldPos，两者都可以为空
+func (db *DB) addFileUsage(pos, oldPos *data.LogRecordPos) {
+	db.usageMu.Lock()
+	defer db.usageMu.Unlock()
+	if pos != nil {
+		db.usageOf(pos.Fid).live += int64(pos.Size)
+	}
+	if oldPos != nil {
+		usage := db.usageOf(oldPos.Fid)
+		usage.live -= int64(oldPos.Size)
+		usage.dead += int64(oldPos.Size)
+	}
+}
+
+// 记录写入之后就是无效数据的记录，例如事务完成的标识
+func (db *DB) addDeadBytes(pos *data.LogRecordPos) {
+	db.usageMu.Lock()
+	defer db.usageMu.Unlock()
+	db.usageOf(pos.Fid).dead += int64(pos.Size)
+}
+
+// 在访问此方法前必须持有 usageMu
+func (db *DB) usageOf(fid uint32) *fileUsage {
+	usage, ok := db.fileUsages[fid]
+	if !ok {
+		usage = new(fileUsage)
+		db.fileUsages[fid] = usage
+	}
+	return usage
+}
+
+// 获取数据文件的使用情况的快照
+func (db *DB) fileUsageSnapshot(fid uint32) fileUsage {
+	db.usageMu.Lock()
+	defer db.usageMu.Unlock()
+	if usage, ok := db.fileUsages[fid]; ok {
+		return *usage
+	}
+	return fileUsage{}
+}
+
+// DataFileStats 返回每个数据文件的统计信息，按照文件 id 从小到大排列
+// LiveBytes 和 DeadBytes 在启动时根据索引重新统计，B+ 树索引不会在启动时加载，只包含启动之后的写入
+func (db *DB) DataFileStats() ([]DataFileStat, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	if db.activeFile == nil {
+		return nil, nil
+	}
+
+	dataFiles := []*data.DataFile{db.activeFile}
+	for _, dataFile := range db.olderFiles {
+		dataFiles = append(dataFiles, dataFile)
+	}
+	stats := make([]DataFileStat, 0, len(dataFiles))
+	for _, dataFile := range dataFiles {
+		stat, err := db.dataFileStat(dataFile)
+		if err != nil {
+			return nil, err
+		}
+		if reads, ok := db.fileReads.Load(dataFile.FileID); ok {
+			stat.Reads = reads.(*metrics.Counter).Value()
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Fid < stats[j].Fid
+	})
+	return stats, nil
+}
+
+// 获取数据文件的统计信息，不包括读取次数
+func (db *DB) dataFileStat(dataFile *data.DataFile) (DataFileStat, error) {
+	info, err := os.Stat(data.GetDataFileName(dataFile.DirPath, dataFile.FileID))
+	if err != nil {
+		return DataFileStat{}, err
+	}
+	usage := db.fileUsageSnapshot(dataFile.FileID)
+	return DataFileStat{
+		Fid:       dataFile.FileID,
+		DirPath:   dataFile.DirPath,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		LiveBytes: usage.live,
+		DeadBytes: usage.dead,
+	}, nil
+}
@@ -0,0 +1,204 @@
+//go:build linux
+
+package fio
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// 直接 IO 要求内存地址、文件偏移以及读写长度都按照块大小对齐
+const directIOAlignment = 4096
+
+// DirectIO 使用 O_DIRECT 打开文件，读写绕过操作系统的页缓存
+// 最后一个不完整的块在内存中保留一份，每次追加写入都会用 0 填充后整块写入磁盘，下一次写入时覆盖
+// 这样进程崩溃时不会丢失已经返回的写入，代价是小数据的写入也要写一整个块
+type DirectIO struct {
+	mu      *sync.RWMutex
+	fd      *os.File
+	size    int64  // 实际写入的数据大小
+	tail    []byte // 最后一个不完整的块，长度为 directIOAlignment
+	tailOff int64  // tail 在文件中的偏移，按块大小对齐
+	dirty   bool   // tail 中是否有还没有写入磁盘的数据，只有写入失败时才会为 true
+}
+
+// NewDirectIOManager 初始化直接 IO
+func NewDirectIOManager(fileName string) (*DirectIO, error) {
+	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR|unix.O_DIRECT, DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := fd.Stat()
+	if err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+
+	d := &DirectIO{
+		mu:   new(sync.RWMutex),
+		fd:   fd,
+		size: stat.Size(),
+		tail: alignedBlock(directIOAlignment),
+	}
+	if err := d.loadTail(); err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// 分配按照 directIOAlignment 对齐的内存
+func alignedBlock(n int) []byte {
+	buf := make([]byte, n+directIOAlignment)
+	shift := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) & (directIOAlignment - 1)); rem != 0 {
+		shift = directIOAlignment - rem
+	}
+	return buf[shift : shift+n : shift+n]
+}
+
+// 从磁盘读取最后一个不完整的块
+func (d *DirectIO) loadTail() error {
+	d.tailOff = d.size &^ (directIOAlignment - 1)
+	clear(d.tail)
+	d.dirty = false
+	if d.size == d.tailOff {
+		return nil
+	}
+	_, err := d.fd.ReadAt(d.tail, d.tailOff)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Read 从文件的给定位置读取对应的数据，最后一个不完整的块从内存中读取
+func (d *DirectIO) Read(b []byte, offset int64) (int, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if offset >= d.size {
+		return 0, io.EOF
+	}
+	end := offset + int64(len(b))
+	if end > d.size {
+		end = d.size
+	}
+
+	var n int
+	// 读取磁盘上完整的块
+	if offset < d.tailOff {
+		diskEnd := end
+		if diskEnd > d.tailOff {
+			diskEnd = d.tailOff
+		}
+		start := offset &^ (directIOAlignment - 1)
+		length := (diskEnd - start + directIOAlignment - 1) &^ (directIOAlignment - 1)
+		buf := alignedBlock(int(length))
+		if _, err := d.fd.ReadAt(buf, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		n = copy(b, buf[offset-start:diskEnd-start])
+		offset = diskEnd
+	}
+	// 读取内存中的最后一个块
+	if offset < end {
+		n += copy(b[n:], d.tail[offset-d.tailOff:end-d.tailOff])
+	}
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write 追加写入字节数组，返回之前所有数据都已经写入磁盘，最后一个不完整的块使用 0 填充
+func (d *DirectIO) Write(b []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var written int
+	for written < len(b) {
+		pos := int(d.size - d.tailOff)
+		n := copy(d.tail[pos:], b[written:])
+		written += n
+		d.size += int64(n)
+		d.dirty = true
+
+		if pos+n == directIOAlignment {
+			if err := d.flushTail(); err != nil {
+				return written, err
+			}
+			d.tailOff += directIOAlignment
+			clear(d.tail)
+			d.dirty = false
+		}
+	}
+	if d.dirty {
+		if err := d.flushTail(); err != nil {
+			return written, err
+		}
+		d.dirty = false
+	}
+	return written, nil
+}
+
+// 将最后一个块整块写入磁盘，不完整的部分使用 0 填充
+func (d *DirectIO) flushTail() error {
+	_, err := d.fd.WriteAt(d.tail, d.tailOff)
+	return err
+}
+
+// Sync 将最后一个不完整的块写入磁盘并持久化
+func (d *DirectIO) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		if err := d.flushTail(); err != nil {
+			return err
+		}
+		d.dirty = false
+	}
+	return d.fd.Sync()
+}
+
+// Truncate 将文件截断到指定大小，之后的写入从该位置开始
+func (d *DirectIO) Truncate(size int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		if err := d.flushTail(); err != nil {
+			return err
+		}
+	}
+	if err := d.fd.Truncate(size); err != nil {
+		return err
+	}
+	d.size = size
+	return d.loadTail()
+}
+
+// Close 写入还在内存中的数据，并将文件截断为实际写入的大小
+func (d *DirectIO) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dirty {
+		if err := d.flushTail(); err != nil {
+			return err
+		}
+		d.dirty = false
+	}
+	if err := d.fd.Truncate(d.size); err != nil {
+		return err
+	}
+	return d.fd.Close()
+}
+
+// Size 获取实际写入的数据大小
+func (d *DirectIO) Size() (int64, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.size, nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package fio
+
+import "errors"
+
+var errDirectIONotSupported = errors.New("direct io is only supported on linux")
+
+// DirectIO 直接 IO，只支持 linux
+type DirectIO struct {
+	FileIO
+}
+
+// NewDirectIOManager 非 linux 系统暂不支持直接 IO
+func NewDirectIOManager(string) (*DirectIO, error) {
+	return nil, errDirectIONotSupported
+}
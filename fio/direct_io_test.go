@@ -0,0 +1,78 @@
+//go:build linux
+
+package fio
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectIO_Write(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "direct-io-a.data")
+	defer destroyFile(path)
+
+	directIO, err := NewDirectIOManager(path)
+	if err != nil {
+		t.Skipf("direct io is not supported: %v", err)
+	}
+
+	// 写入跨越多个块的数据
+	content := bytes.Repeat([]byte("bitcask-kv"), 1000)
+	n, err := directIO.Write(content)
+	assert.Nil(t, err)
+	assert.Equal(t, len(content), n)
+	_, err = directIO.Write([]byte("storage"))
+	assert.Nil(t, err)
+	content = append(content, []byte("storage")...)
+
+	// 不需要 Sync，其他文件句柄也能读到最后一个不完整的块
+	raw, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, content, raw[:len(content)])
+
+	// 读取跨越磁盘上的块和内存中的块的数据
+	b := make([]byte, 100)
+	_, err = directIO.Read(b, 8150)
+	assert.Nil(t, err)
+	assert.Equal(t, content[8150:8250], b)
+
+	b = make([]byte, 10)
+	n, err = directIO.Read(b, int64(len(content)-3))
+	assert.Equal(t, 3, n)
+	assert.Equal(t, io.EOF, err)
+
+	err = directIO.Sync()
+	assert.Nil(t, err)
+	err = directIO.Close()
+	assert.Nil(t, err)
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(content)), stat.Size())
+
+	// 重新打开之后继续追加
+	directIO2, err := NewDirectIOManager(path)
+	assert.Nil(t, err)
+	_, err = directIO2.Write([]byte("!"))
+	assert.Nil(t, err)
+	content = append(content, '!')
+	b = make([]byte, len(content))
+	_, err = directIO2.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, content, b)
+
+	err = directIO2.Truncate(5000)
+	assert.Nil(t, err)
+	size, err := directIO2.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5000), size)
+	b = make([]byte, 100)
+	_, err = directIO2.Read(b, 4900)
+	assert.Nil(t, err)
+	assert.Equal(t, content[4900:5000], b)
+	err = directIO2.Close()
+	assert.Nil(t, err)
+}
@@ -36,6 +36,11 @@ func (fio *FileIO) Sync() error {
 	return fio.fd.Sync()
 }
 
+// Truncate 截断文件，文件以追加模式打开，之后的写入从截断的位置开始
+func (fio *FileIO) Truncate(size int64) error {
+	return fio.fd.Truncate(size)
+}
+
 // Close 关闭文件
 func (fio *FileIO) Close() error {
 	return fio.fd.Close()
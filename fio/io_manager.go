@@ -8,8 +8,14 @@ const (
 	// StandardFIO 标准文件 IO
 	StandardFIO FileIOType = iota
 
-	// MemoryMap 内存文件映射
+	// MemoryMap 内存文件映射，只读，仅用于启动时加速索引的加载
 	MemoryMap
+
+	// MemoryMapRW 可读写的内存文件映射，写入时直接拷贝到映射的内存中
+	MemoryMapRW
+
+	// DirectFIO 直接 IO（O_DIRECT），读写绕过操作系统的页缓存，只支持 linux
+	DirectFIO
 )
 
 // IOManger 抽象 IO 管理接口，可以接入不同的IO类型，目前支持标准文件IO
@@ -28,15 +34,23 @@ type IOManager interface {
 
 	// Size 获取到文件大小
 	Size() (int64, error)
+
+	// Truncate 将文件截断到指定大小，之后的写入从该位置开始
+	Truncate(int64) error
 }
 
-// NewIOManger 初始化 IOManger， 目前只支持 FileIO
-func NewIOManager(fileName string, ioType FileIOType) (IOManager, error) {
+// NewIOManger 初始化 IOManger
+// mapSize 为 MemoryMapRW 预先映射的大小，一般为活跃文件的阈值，其他类型忽略该参数
+func NewIOManager(fileName string, ioType FileIOType, mapSize int64) (IOManager, error) {
 	switch ioType {
 	case StandardFIO:
 		return NewFileIOManager(fileName)
 	case MemoryMap:
 		return NewMMapIOManager(fileName)
+	case MemoryMapRW:
+		return NewMMapRWIOManager(fileName, mapSize)
+	case DirectFIO:
+		return NewDirectIOManager(fileName)
 	default:
 		panic("unsupported io type")
 	}
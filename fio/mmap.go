@@ -1,10 +1,13 @@
 package fio
 
 import (
+	"errors"
 	"golang.org/x/exp/mmap"
 	"os"
 )
 
+var ErrReadOnlyMMap = errors.New("memory map io is read only, use MemoryMapRW instead")
+
 // MMap IO，内存文件映射
 type MMap struct {
 	readerAt *mmap.ReaderAt
@@ -28,11 +31,15 @@ func (mmap *MMap) Read(b []byte, offset int64) (int, error) {
 }
 
 func (mmap *MMap) Write([]byte) (int, error) {
-	panic("not implemented")
+	return 0, ErrReadOnlyMMap
 }
 
 func (mmap *MMap) Sync() error {
-	panic("not implemented")
+	return nil
+}
+
+func (mmap *MMap) Truncate(int64) error {
+	return ErrReadOnlyMMap
 }
 
 func (mmap *MMap) Close() error {
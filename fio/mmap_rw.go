@@ -0,0 +1,154 @@
+//go:build !windows
+
+package fio
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// MMapRW 可读写的内存文件映射
+// 打开时将文件扩展到 mapSize 并整体映射，写入直接拷贝到映射的内存中，关闭时将文件截断为实际写入的大小
+type MMapRW struct {
+	mu     *sync.RWMutex
+	fd     *os.File
+	data   []byte // 映射的内存，长度与磁盘上的文件大小一致
+	size   int64  // 实际写入的数据大小
+	resize bool   // 上次持久化之后文件大小是否发生过变化
+}
+
+// NewMMapRWIOManager 初始化可读写的 MMap IO，mapSize 小于文件大小时按照文件大小进行映射
+func NewMMapRWIOManager(fileName string, mapSize int64) (*MMapRW, error) {
+	fd, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, DataFilePerm)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := fd.Stat()
+	if err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+
+	m := &MMapRW{mu: new(sync.RWMutex), fd: fd, size: stat.Size()}
+	if mapSize < m.size {
+		mapSize = m.size
+	}
+	if err := m.remap(mapSize); err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// 将文件扩展到 length 并重新映射
+func (m *MMapRW) remap(length int64) error {
+	if m.data != nil {
+		if err := unix.Munmap(m.data); err != nil {
+			return err
+		}
+		m.data = nil
+	}
+	if length == 0 {
+		return nil
+	}
+	if length > m.size {
+		if err := m.fd.Truncate(length); err != nil {
+			return err
+		}
+		m.resize = true
+	}
+	data, err := unix.Mmap(int(m.fd.Fd()), 0, int(length), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	m.data = data
+	return nil
+}
+
+// Read 从文件的给定位置读取对应的数据，不会读到实际写入的数据之外
+func (m *MMapRW) Read(b []byte, offset int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if offset >= m.size {
+		return 0, io.EOF
+	}
+	n := copy(b, m.data[offset:m.size])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write 写入字节数组到文件中，映射的空间不够时扩大一倍
+func (m *MMapRW) Write(b []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	need := m.size + int64(len(b))
+	if need > int64(len(m.data)) {
+		length := int64(len(m.data)) * 2
+		if length < need {
+			length = need
+		}
+		if err := m.remap(length); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(m.data[m.size:], b)
+	m.size += int64(n)
+	return n, nil
+}
+
+// Sync 持久化数据，文件大小发生变化时还需要持久化文件的元数据
+func (m *MMapRW) Sync() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data != nil {
+		if err := unix.Msync(m.data, unix.MS_SYNC); err != nil {
+			return err
+		}
+	}
+	if m.resize {
+		if err := m.fd.Sync(); err != nil {
+			return err
+		}
+		m.resize = false
+	}
+	return nil
+}
+
+// Truncate 丢弃 size 之后写入的数据，映射的大小不变
+func (m *MMapRW) Truncate(size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if size < m.size {
+		clear(m.data[size:m.size])
+	}
+	m.size = size
+	return nil
+}
+
+// Close 解除映射，并将文件截断为实际写入的大小
+func (m *MMapRW) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.data != nil {
+		if err := unix.Munmap(m.data); err != nil {
+			return err
+		}
+		m.data = nil
+	}
+	if err := m.fd.Truncate(m.size); err != nil {
+		return err
+	}
+	return m.fd.Close()
+}
+
+// Size 获取实际写入的数据大小
+func (m *MMapRW) Size() (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.size, nil
+}
@@ -0,0 +1,89 @@
+//go:build !windows
+
+package fio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMMapRW_Write(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "mmap-rw-a.data")
+	defer destroyFile(path)
+
+	mmapIO, err := NewMMapRWIOManager(path, 16)
+	assert.Nil(t, err)
+
+	// 预先映射的空间不算在文件大小中
+	size, err := mmapIO.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), size)
+
+	n, err := mmapIO.Write([]byte("bitcask kv"))
+	assert.Nil(t, err)
+	assert.Equal(t, 10, n)
+	// 超过预先映射的空间
+	n, err = mmapIO.Write([]byte("storage engine"))
+	assert.Nil(t, err)
+	assert.Equal(t, 14, n)
+	err = mmapIO.Sync()
+	assert.Nil(t, err)
+
+	b := make([]byte, 7)
+	n, err = mmapIO.Read(b, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("storage"), b)
+
+	// 不能读到实际写入的数据之外
+	b = make([]byte, 10)
+	n, err = mmapIO.Read(b, 20)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, io.EOF, err)
+
+	// 关闭时截断为实际写入的大小
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+	stat, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(24), stat.Size())
+
+	// 重新打开之后继续追加
+	mmapIO2, err := NewMMapRWIOManager(path, 64)
+	assert.Nil(t, err)
+	_, err = mmapIO2.Write([]byte("!"))
+	assert.Nil(t, err)
+	b = make([]byte, 25)
+	_, err = mmapIO2.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("bitcask kvstorage engine!"), b)
+	err = mmapIO2.Close()
+	assert.Nil(t, err)
+}
+
+func TestMMapRW_Truncate(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "mmap-rw-b.data")
+	defer destroyFile(path)
+
+	mmapIO, err := NewMMapRWIOManager(path, 32)
+	assert.Nil(t, err)
+	_, err = mmapIO.Write([]byte("aabbcc"))
+	assert.Nil(t, err)
+
+	err = mmapIO.Truncate(2)
+	assert.Nil(t, err)
+	_, err = mmapIO.Write([]byte("dd"))
+	assert.Nil(t, err)
+	size, err := mmapIO.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), size)
+
+	b := make([]byte, 4)
+	_, err = mmapIO.Read(b, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("aadd"), b)
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+}
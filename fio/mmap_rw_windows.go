@@ -0,0 +1,17 @@
+//go:build windows
+
+package fio
+
+import "errors"
+
+var errMMapRWNotSupported = errors.New("read/write memory map io is not supported on windows")
+
+// MMapRW 可读写的内存文件映射，windows 上暂不支持
+type MMapRW struct {
+	FileIO
+}
+
+// NewMMapRWIOManager windows 上暂不支持可读写的内存文件映射
+func NewMMapRWIOManager(string, int64) (*MMapRW, error) {
+	return nil, errMMapRWNotSupported
+}
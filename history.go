@@ -0,0 +1,53 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"time"
+)
+
+// KeyVersion key 的一个历史版本
+type KeyVersion struct {
+	Value     []byte    // 这个版本的 value，删除标记为空
+	Seq       uint64    // 写入时分配的序列号，同一个事务中的记录序列号相同
+	Timestamp time.Time // 写入的时间
+	Deleted   bool      // 是否是删除标记
+}
+
+// History 获取 key 最近的 limit 个版本（包括当前版本和删除标记），从新到旧排列，limit 小于等于 0 表示获取所有记录的版本
+// 只能获取到开启 HistoryVersions 之后写入、并且还保留在数据文件中的版本，merge 时通过 MergeKeepVersions 保留历史版本
+// 已经被删除的 key 的版本在 merge 之后不再保留在内存中
+func (db *DB) History(key []byte, limit int) ([]*KeyVersion, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+	if db.versions == nil {
+		return nil, ErrHistoryNotEnabled
+	}
+
+	// 在读锁中获取版本的位置，读取期间不会被并发的写入修改
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	positions := db.versions.Get(key)
+	if limit > 0 && limit < len(positions) {
+		positions = positions[:limit]
+	}
+
+	versions := make([]*KeyVersion, 0, len(positions))
+	for _, pos := range positions {
+		logRecord, err := db.readLogRecord(pos)
+		if err != nil {
+			return nil, err
+		}
+		version := &KeyVersion{Deleted: logRecord.Type == data.LogRecordDeleted || logRecord.Type == data.LogRecordRangeDeleted}
+		if !version.Deleted {
+			version.Value = logRecord.Value
+		}
+		if logRecord.Version != nil {
+			version.Seq = db.recordSeq(logRecord)
+			version.Timestamp = time.Unix(0, logRecord.Version.Timestamp)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
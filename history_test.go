@@ -0,0 +1,104 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_History(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-history")
+	opts.DirPath = dir
+	opts.DataFileMergeRatio = 0
+	opts.HistoryVersions = 5
+	opts.MergeKeepVersions = 3
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	key := []byte("name")
+	for _, value := range []string{"v1", "v2", "v3", "v4"} {
+		assert.Nil(t, db.Put(key, []byte(value)))
+	}
+	assert.Nil(t, db.Delete(key))
+	assert.Nil(t, db.Put(key, []byte("v6")))
+	assert.Nil(t, db.Put([]byte("other"), []byte("value")))
+
+	// 只记录最近的 5 个版本，从新到旧排列
+	versions, err := db.History(key, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(versions))
+	assert.Equal(t, []byte("v6"), versions[0].Value)
+	assert.True(t, versions[1].Deleted)
+	assert.Nil(t, versions[1].Value)
+	assert.Equal(t, []byte("v2"), versions[4].Value)
+	for i := 1; i < len(versions); i++ {
+		assert.Greater(t, versions[i-1].Seq, versions[i].Seq)
+		assert.False(t, versions[i].Timestamp.IsZero())
+	}
+
+	versions, err = db.History(key, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(versions))
+
+	// 事务中写入的版本
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put(key, []byte("v7")))
+	assert.Nil(t, wb.Commit())
+	versions, err = db.History(key, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v7"), versions[0].Value)
+	assert.Greater(t, versions[0].Seq, versions[1].Seq)
+	latestSeq := versions[0].Seq
+
+	// 已经删除的 key 的版本在 merge 之后不再保留在内存中
+	assert.Nil(t, db.Put([]byte("deleted"), []byte("value")))
+	assert.Nil(t, db.Delete([]byte("deleted")))
+	versions, err = db.History([]byte("deleted"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(versions))
+
+	// merge 之后只保留最近的 3 个版本
+	assert.Nil(t, db.Merge())
+	versions, err = db.History([]byte("deleted"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(versions))
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	versions, err = db2.History(key, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(versions))
+	assert.Equal(t, []byte("v7"), versions[0].Value)
+	assert.Equal(t, []byte("v6"), versions[1].Value)
+	assert.True(t, versions[2].Deleted)
+	assert.Equal(t, latestSeq, versions[0].Seq)
+	val, err := db2.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v7"), val)
+
+	// 重启之后的序列号继续递增
+	assert.Nil(t, db2.Put(key, []byte("v8")))
+	versions, err = db2.History(key, 1)
+	assert.Nil(t, err)
+	assert.Greater(t, versions[0].Seq, latestSeq)
+	assert.Nil(t, db2.Close())
+
+	// 没有开启时不能查询历史版本
+	opts.HistoryVersions = 0
+	opts.MergeKeepVersions = 0
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	_, err = db3.History(key, 0)
+	assert.Equal(t, ErrHistoryNotEnabled, err)
+	val, err = db3.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v8"), val)
+
+	// 保留的版本数量不能超过记录的版本数量
+	opts.MergeKeepVersions = 1
+	_, err = Open(opts)
+	assert.NotNil(t, err)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	bitcask_go "myRosedb"
@@ -11,6 +12,9 @@ import (
 
 var db *bitcask_go.DB
 
+// 是否注册 /metrics 接口
+var enableMetrics = flag.Bool("metrics", false, "expose prometheus metrics at /metrics")
+
 func init() {
 	// 初始化 DB 实例
 	var err error
@@ -105,13 +109,29 @@ func handleStat(writer http.ResponseWriter, request *http.Request) {
 	_ = json.NewEncoder(writer).Encode(stat)
 }
 
+func handleMetrics(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_ = db.Metrics().WritePrometheus(writer)
+}
+
 func main() {
+	flag.Parse()
+
 	// 注册处理方法
 	http.HandleFunc("/bitcask/put", handlePut)
 	http.HandleFunc("/bitcask/get", handleGet)
 	http.HandleFunc("/bitcask/delete", handleDelete)
 	http.HandleFunc("/bitcask/listkeys", handleListKeys)
 	http.HandleFunc("/bitcask/stat", handleStat)
+	// 开启之后才提供 Prometheus 指标
+	if *enableMetrics {
+		http.HandleFunc("/metrics", handleMetrics)
+	}
 	// 启动 HTTP 服务
 	http.ListenAndServe("localhost:8080", nil)
 }
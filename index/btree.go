@@ -42,7 +42,10 @@ func (bt *BTree) Put(key []byte, pos *data.LogRecordPos) *data.LogRecordPos {
 
 func (bt *BTree) Get(key []byte) *data.LogRecordPos {
 	it := Item{key: key}
+	// merge 等操作会和写入并发读取索引，读取时也需要加锁
+	bt.lock.RLock()
 	btreeItem := bt.tree.Get(&it)
+	bt.lock.RUnlock()
 	if btreeItem == nil {
 		return nil
 	}
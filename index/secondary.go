@@ -0,0 +1,109 @@
+package index
+
+import (
+	"bytes"
+	"github.com/google/btree"
+	"sync"
+)
+
+// SecondaryIndex 二级索引，记录索引 key 到主键的对应关系，按照 (索引 key, 主键) 排序，并发安全
+type SecondaryIndex struct {
+	lock    *sync.RWMutex
+	tree    *btree.BTree
+	entries map[string][][]byte // 主键 -> 这个主键对应的所有索引 key
+}
+
+// 二级索引中的一项
+type secondaryItem struct {
+	indexKey []byte
+	key      []byte
+}
+
+func (ai *secondaryItem) Less(bi btree.Item) bool {
+	b := bi.(*secondaryItem)
+	if c := bytes.Compare(ai.indexKey, b.indexKey); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(ai.key, b.key) < 0
+}
+
+// NewSecondaryIndex 初始化二级索引
+func NewSecondaryIndex() *SecondaryIndex {
+	return &SecondaryIndex{
+		lock:    new(sync.RWMutex),
+		tree:    btree.New(32),
+		entries: make(map[string][][]byte),
+	}
+}
+
+// Set 用 indexKeys 替换主键 key 原来的所有索引 key，indexKeys 为空时等同于 Remove
+func (si *SecondaryIndex) Set(key []byte, indexKeys [][]byte) {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+	si.remove(key)
+	si.add(key, indexKeys)
+}
+
+// Remove 删除主键 key 的所有索引 key
+func (si *SecondaryIndex) Remove(key []byte) {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+	si.remove(key)
+}
+
+// Ascend 按照 (索引 key, 主键) 的顺序遍历索引 key 在 [start, end) 范围内的所有项，end 为空表示没有终点，fn 返回 false 时终止遍历
+func (si *SecondaryIndex) Ascend(start, end []byte, fn func(indexKey, key []byte) bool) {
+	si.lock.RLock()
+	defer si.lock.RUnlock()
+	si.tree.AscendGreaterOrEqual(&secondaryItem{indexKey: start}, func(it btree.Item) bool {
+		item := it.(*secondaryItem)
+		if len(end) > 0 && bytes.Compare(item.indexKey, end) >= 0 {
+			return false
+		}
+		return fn(item.indexKey, item.key)
+	})
+}
+
+// Size 索引中主键的数量
+func (si *SecondaryIndex) Size() int {
+	si.lock.RLock()
+	defer si.lock.RUnlock()
+	return len(si.entries)
+}
+
+// Reset 清空索引
+func (si *SecondaryIndex) Reset() {
+	si.lock.Lock()
+	defer si.lock.Unlock()
+	si.tree.Clear(false)
+	si.entries = make(map[string][][]byte)
+}
+
+// 在访问此方法前必须持有写锁，key 和 indexKeys 会被复制，调用方之后可以继续修改
+func (si *SecondaryIndex) add(key []byte, indexKeys [][]byte) {
+	if len(indexKeys) == 0 {
+		return
+	}
+	key = append([]byte(nil), key...)
+	stored := make([][]byte, 0, len(indexKeys))
+	for _, indexKey := range indexKeys {
+		item := &secondaryItem{indexKey: append([]byte(nil), indexKey...), key: key}
+		// 同一个索引 key 只记录一次
+		if si.tree.ReplaceOrInsert(item) == nil {
+			stored = append(stored, item.indexKey)
+		}
+	}
+	si.entries[string(key)] = stored
+}
+
+// 在访问此方法前必须持有写锁
+func (si *SecondaryIndex) remove(key []byte) {
+	indexKeys, ok := si.entries[string(key)]
+	if !ok {
+		return
+	}
+	for _, indexKey := range indexKeys {
+		si.tree.Delete(&secondaryItem{indexKey: indexKey, key: key})
+	}
+	delete(si.entries, string(key))
+}
@@ -0,0 +1,43 @@
+package index
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSecondaryIndex(t *testing.T) {
+	si := NewSecondaryIndex()
+
+	si.Set([]byte("u1"), [][]byte{[]byte("beijing"), []byte("age-20")})
+	si.Set([]byte("u2"), [][]byte{[]byte("beijing"), []byte("beijing")})
+	si.Set([]byte("u3"), [][]byte{[]byte("shanghai")})
+	assert.Equal(t, 3, si.Size())
+
+	scan := func(start, end string) []string {
+		var keys []string
+		var endKey []byte
+		if end != "" {
+			endKey = []byte(end)
+		}
+		si.Ascend([]byte(start), endKey, func(indexKey, key []byte) bool {
+			keys = append(keys, string(indexKey)+"/"+string(key))
+			return true
+		})
+		return keys
+	}
+	// 相同的索引 key 按照主键排序，重复的索引 key 只记录一次
+	assert.Equal(t, []string{"beijing/u1", "beijing/u2"}, scan("beijing", "beijinh"))
+	assert.Equal(t, []string{"age-20/u1", "beijing/u1", "beijing/u2", "shanghai/u3"}, scan("", ""))
+
+	// 替换主键原来的索引 key
+	si.Set([]byte("u1"), [][]byte{[]byte("shanghai")})
+	assert.Equal(t, []string{"beijing/u2"}, scan("beijing", "beijinh"))
+	assert.Equal(t, []string{"shanghai/u1", "shanghai/u3"}, scan("shanghai", ""))
+
+	si.Remove([]byte("u2"))
+	assert.Nil(t, scan("beijing", "beijinh"))
+
+	si.Reset()
+	assert.Equal(t, 0, si.Size())
+	assert.Nil(t, scan("", ""))
+}
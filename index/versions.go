@@ -0,0 +1,98 @@
+package index
+
+import (
+	"myRosedb/data"
+	"sync"
+)
+
+// VersionChain 记录每个 key 最近若干个版本（包括删除标记）在数据文件中的位置，并发安全
+// 为空时所有方法都不做任何操作
+type VersionChain struct {
+	limit  int // 每个 key 最多保留的版本数量
+	lock   *sync.RWMutex
+	chains map[string][]*data.LogRecordPos // 从新到旧排列
+}
+
+// NewVersionChain 初始化 VersionChain，limit 为每个 key 最多保留的版本数量
+func NewVersionChain(limit int) *VersionChain {
+	return &VersionChain{
+		limit:  limit,
+		lock:   new(sync.RWMutex),
+		chains: make(map[string][]*data.LogRecordPos),
+	}
+}
+
+// Add 记录 key 最新版本的位置，超出数量上限的旧版本会被丢弃
+func (vc *VersionChain) Add(key []byte, pos *data.LogRecordPos) {
+	if vc == nil {
+		return
+	}
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+
+	chain := vc.chains[string(key)]
+	if len(chain) < vc.limit {
+		chain = append(chain, nil)
+	}
+	copy(chain[1:], chain)
+	chain[0] = pos
+	vc.chains[string(key)] = chain
+}
+
+// Get 获取 key 所有版本的位置，从新到旧排列
+func (vc *VersionChain) Get(key []byte) []*data.LogRecordPos {
+	if vc == nil {
+		return nil
+	}
+	vc.lock.RLock()
+	defer vc.lock.RUnlock()
+
+	chain := vc.chains[string(key)]
+	positions := make([]*data.LogRecordPos, len(chain))
+	copy(positions, chain)
+	return positions
+}
+
+// Contains 判断 pos 是否是 key 最新的 n 个版本之一
+func (vc *VersionChain) Contains(key []byte, pos *data.LogRecordPos, n int) bool {
+	if vc == nil {
+		return false
+	}
+	vc.lock.RLock()
+	defer vc.lock.RUnlock()
+
+	chain := vc.chains[string(key)]
+	if n < len(chain) {
+		chain = chain[:n]
+	}
+	for _, p := range chain {
+		if p.Fid == pos.Fid && p.Offset == pos.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune 删除 fn 返回 true 的 key 的所有版本
+func (vc *VersionChain) Prune(fn func(key []byte) bool) {
+	if vc == nil {
+		return
+	}
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+	for key := range vc.chains {
+		if fn([]byte(key)) {
+			delete(vc.chains, key)
+		}
+	}
+}
+
+// Reset 清空所有 key 的版本信息
+func (vc *VersionChain) Reset() {
+	if vc == nil {
+		return
+	}
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+	vc.chains = make(map[string][]*data.LogRecordPos)
+}
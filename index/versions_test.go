@@ -0,0 +1,39 @@
+package index
+
+import (
+	"github.com/stretchr/testify/assert"
+	"myRosedb/data"
+	"testing"
+)
+
+func TestVersionChain(t *testing.T) {
+	vc := NewVersionChain(2)
+
+	vc.Add([]byte("a"), &data.LogRecordPos{Fid: 1, Offset: 0})
+	vc.Add([]byte("a"), &data.LogRecordPos{Fid: 1, Offset: 10})
+	vc.Add([]byte("a"), &data.LogRecordPos{Fid: 2, Offset: 0})
+
+	// 只保留最新的两个版本
+	chain := vc.Get([]byte("a"))
+	assert.Equal(t, 2, len(chain))
+	assert.Equal(t, uint32(2), chain[0].Fid)
+	assert.Equal(t, int64(10), chain[1].Offset)
+
+	assert.True(t, vc.Contains([]byte("a"), &data.LogRecordPos{Fid: 1, Offset: 10}, 2))
+	assert.False(t, vc.Contains([]byte("a"), &data.LogRecordPos{Fid: 1, Offset: 10}, 1))
+	assert.False(t, vc.Contains([]byte("a"), &data.LogRecordPos{Fid: 1, Offset: 0}, 2))
+	assert.Equal(t, 0, len(vc.Get([]byte("b"))))
+
+	vc.Add([]byte("b"), &data.LogRecordPos{Fid: 3, Offset: 0})
+	vc.Prune(func(key []byte) bool { return string(key) == "b" })
+	assert.Equal(t, 0, len(vc.Get([]byte("b"))))
+	assert.Equal(t, 2, len(vc.Get([]byte("a"))))
+
+	vc.Reset()
+	assert.Equal(t, 0, len(vc.Get([]byte("a"))))
+
+	// 为空时不做任何操作
+	var empty *VersionChain
+	empty.Add([]byte("a"), &data.LogRecordPos{})
+	assert.Nil(t, empty.Get([]byte("a")))
+}
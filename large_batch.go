@@ -0,0 +1,209 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// LargeBatch 不限制大小的批量写入，每次写入都直接追加到数据文件中，内存中只保存每条记录的位置
+// 创建时预留一个事务序列号，写入的记录都带有这个序列号，写入事务完成的标识之后才对外可见
+// 对外的序列号在提交时分配，保存在事务完成的标识中，与其他写入的顺序一致
+// 没有提交就崩溃时，重启加载数据时会丢弃这些记录
+// 提交时按照记录的位置重新读取这个批次写入的记录来更新内存索引，期间会一直持有写锁，耗时与批次的大小成正比
+// 没有结束的 LargeBatch 开始之后写入的数据文件不会被 merge，DropAll 也会失败，不再使用时需要 Commit 或者 Rollback
+// 没有结束就不再被引用的 LargeBatch 会在垃圾回收时自动回滚
+type LargeBatch struct {
+	options   WriteBatchOptions // 只有 SyncWrites 生效
+	mu        *sync.Mutex
+	db        *DB
+	seqNo     uint64              // 预留的事务序列号，只用来标识这个批次的记录
+	startFid  uint32              // 创建时的活跃文件 id，记录只会写入这个文件及之后的文件
+	positions []data.LogRecordPos // 按照写入顺序排列的每条记录的位置
+	finished  bool                // 是否已经提交或者回滚
+}
+
+// NewLargeBatch 初始化 LargeBatch，opts 中的 MaxBatchNum 和 MaxBatchBytes 不生效
+func (db *DB) NewLargeBatch(opts WriteBatchOptions) *LargeBatch {
+	// 只有当是 B+ 树，并且存储事务序列号不存在，并且不是第一次初始化，都禁用batch
+	if db.options.IndexType == BPlusTree && !db.seqNoFileExists && !db.isInital {
+		panic("cannot use write batch, seq no file not exists")
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	lb := &LargeBatch{
+		options: opts,
+		mu:      new(sync.Mutex),
+		db:      db,
+		seqNo:   atomic.AddUint64(&db.seqNo, 1),
+	}
+	if db.activeFile != nil {
+		lb.startFid = db.activeFile.FileID
+	}
+	db.largeBatches[lb.seqNo] = lb.startFid
+	// 忘记结束的批次在不再被引用之后回滚，避免一直阻止 merge
+	runtime.SetFinalizer(lb, func(lb *LargeBatch) {
+		_ = lb.Rollback()
+	})
+	return lb
+}
+
+// Put 写入数据，数据直接追加到数据文件中，提交之前不可见
+func (lb *LargeBatch) Put(key []byte, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	if err := lb.db.checkKeyValueSize(key, int64(len(value))); err != nil {
+		return err
+	}
+	return lb.append(&data.LogRecord{Key: key, Value: value, Type: data.LogRecordNormal})
+}
+
+// Delete 删除数据，不检查 key 是否存在，每次都会写入删除标记
+func (lb *LargeBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return lb.append(&data.LogRecord{Key: key, Type: data.LogRecordDeleted})
+}
+
+func (lb *LargeBatch) append(logRecord *data.LogRecord) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.finished {
+		return ErrLargeBatchFinished
+	}
+
+	logRecord.Key = logRecordKeyWithSeq(logRecord.Key, lb.seqNo)
+	logRecord.Version = lb.db.newRecordVersion(lb.seqNo)
+	pos, err := lb.db.appendLogRecordWithLock(logRecord)
+	if err != nil {
+		return err
+	}
+	lb.positions = append(lb.positions, *pos)
+	return nil
+}
+
+// Len 已经写入的记录数量
+func (lb *LargeBatch) Len() int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return len(lb.positions)
+}
+
+// Commit 写入事务完成的标识，之后按照位置读取写入的记录更新内存索引
+func (lb *LargeBatch) Commit() error {
+	_, err := lb.CommitWithSeq()
+	return err
+}
+
+// CommitWithSeq 提交批次，返回提交时分配的序列号，没有写入任何数据时返回 0
+func (lb *LargeBatch) CommitWithSeq() (uint64, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.finished {
+		return 0, ErrLargeBatchFinished
+	}
+
+	db := lb.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(lb.positions) == 0 {
+		lb.finish()
+		return 0, nil
+	}
+
+	// 写一条标识事务完成提交的数据，之后这个批次中的数据才是有效的，版本信息中是提交时分配的序列号
+	seqNo := atomic.AddUint64(&db.seqNo, 1)
+	finishedPos, err := db.appendLogRecord(&data.LogRecord{
+		Key:     logRecordKeyWithSeq(txnFinKey, lb.seqNo),
+		Type:    data.LogRecordTxnFinished,
+		Version: db.newRecordVersion(seqNo),
+	})
+	if err != nil {
+		return 0, err
+	}
+	db.addDeadBytes(finishedPos)
+	db.txnSeqs[lb.seqNo] = seqNo
+	positions := lb.positions
+	lb.finish()
+
+	// 根据配置决定是否进行持久化
+	if lb.options.SyncWrites {
+		if err := db.syncActiveFile(); err != nil {
+			return 0, err
+		}
+	}
+
+	// 按照写入顺序读取这个批次中的记录，更新内存索引
+	for i := range positions {
+		pos := &positions[i]
+		logRecord, err := db.readLogRecord(pos)
+		if err != nil {
+			return 0, err
+		}
+		realKey, _ := parseLogRecordKey(logRecord.Key)
+		var oldPos *data.LogRecordPos
+		if logRecord.Type == data.LogRecordDeleted {
+			oldPos, _ = db.index.Delete(realKey)
+			db.reclaimSize += int64(pos.Size)
+		} else {
+			oldPos = db.index.Put(realKey, pos)
+		}
+		if oldPos != nil {
+			db.reclaimSize += int64(oldPos.Size)
+			db.valueCache.Remove(valueCacheKey(oldPos))
+		}
+		db.addFileUsage(pos, oldPos)
+		db.versions.Add(realKey, pos)
+		if logRecord.Type == data.LogRecordDeleted {
+			db.indexDelete(realKey)
+		} else {
+			db.indexWrite(realKey, logRecord.Value)
+		}
+	}
+	return seqNo, nil
+}
+
+// Rollback 放弃这个批次，已经写入的记录成为无效数据，在 merge 时被清理
+func (lb *LargeBatch) Rollback() error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if lb.finished {
+		return ErrLargeBatchFinished
+	}
+
+	lb.db.mu.Lock()
+	defer lb.db.mu.Unlock()
+	for i := range lb.positions {
+		lb.db.addDeadBytes(&lb.positions[i])
+		lb.db.reclaimSize += int64(lb.positions[i].Size)
+	}
+	lb.finish()
+	return nil
+}
+
+// 结束这个批次，之后它写入的数据文件可以参与 merge
+// 在访问此方法前必须持有 LargeBatch 和数据库的互斥锁
+func (lb *LargeBatch) finish() {
+	lb.finished = true
+	lb.positions = nil
+	delete(lb.db.largeBatches, lb.seqNo)
+	runtime.SetFinalizer(lb, nil)
+}
+
+// 没有结束的 LargeBatch 中最小的起始文件 id，这个文件及之后的文件都不能被 merge
+// 在访问此方法前必须持有互斥锁
+func (db *DB) minLargeBatchFid() (uint32, bool) {
+	var minFid uint32
+	var ok bool
+	for _, fid := range db.largeBatches {
+		if !ok || fid < minFid {
+			minFid, ok = fid, true
+		}
+	}
+	return minFid, ok
+}
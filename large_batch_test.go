@@ -0,0 +1,94 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"myRosedb/utils"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDB_LargeBatch(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-large-batch")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("exist"), []byte("value")))
+
+	// 超过 MaxBatchNum 的数据量，写入的数据分布在多个数据文件中
+	lb := db.NewLargeBatch(WriteBatchOptions{MaxBatchNum: 10, SyncWrites: true})
+	startFid := db.activeFile.FileID
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, lb.Put(utils.GetTestKey(i), utils.RandomValue(128)))
+	}
+	assert.Nil(t, lb.Delete([]byte("exist")))
+	assert.Equal(t, 2001, lb.Len())
+	assert.True(t, db.activeFile.FileID > startFid)
+
+	// 提交之前不可见
+	_, err = db.Get(utils.GetTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db.Get([]byte("exist"))
+	assert.Nil(t, err)
+
+	// 批次写入的数据文件不会被 merge
+	for _, dataFile := range db.pickMergeFiles() {
+		assert.True(t, dataFile.FileID < startFid)
+	}
+
+	assert.Nil(t, lb.Commit())
+	assert.Equal(t, ErrLargeBatchFinished, lb.Commit())
+	assert.Equal(t, ErrLargeBatchFinished, lb.Put([]byte("name"), []byte("value")))
+	assert.Equal(t, 2000, db.index.Size())
+	_, err = db.Get(utils.GetTestKey(1999))
+	assert.Nil(t, err)
+	_, err = db.Get([]byte("exist"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 回滚之后的数据不可见
+	lb2 := db.NewLargeBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, lb2.Put([]byte("rollback"), []byte("value")))
+	assert.Nil(t, lb2.Rollback())
+	_, err = db.Get([]byte("rollback"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 没有提交的数据重启之后被丢弃
+	lb3 := db.NewLargeBatch(DefaultWriteBatchOptions)
+	for i := 2000; i < 2500; i++ {
+		assert.Nil(t, lb3.Put(utils.GetTestKey(i), utils.RandomValue(128)))
+	}
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, 2000, db2.index.Size())
+	_, err = db2.Get(utils.GetTestKey(2000))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db2.Get([]byte("rollback"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = db2.Get(utils.GetTestKey(0))
+	assert.Nil(t, err)
+
+	// 没有结束就不再被引用的批次在垃圾回收时回滚，之后的数据文件可以参与 merge
+	func() {
+		lb := db2.NewLargeBatch(DefaultWriteBatchOptions)
+		assert.Nil(t, lb.Put([]byte("abandoned"), []byte("value")))
+	}()
+	pending := func() int {
+		db2.mu.Lock()
+		defer db2.mu.Unlock()
+		return len(db2.largeBatches)
+	}
+	for i := 0; i < 100 && pending() > 0; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, pending())
+	_, err = db2.Get([]byte("abandoned"))
+	assert.Equal(t, ErrKeyNotFound, err)
+	assert.Nil(t, db2.Close())
+}
@@ -0,0 +1,58 @@
+package bitcask_go
+
+// Logger 存储引擎的日志接口
+// 方法签名与 *slog.Logger 保持一致，可以直接传入 slog.Default() 或 slog.New(handler)
+// args 为成对出现的 key/value
+//
+// 引擎会输出以下事件，字段名称保持稳定：
+//
+//	msg                            level  fields
+//	db opened                      Info   dir, data_files, keys, seq_no, duration
+//	directory lock failed          Error  dir, err
+//	hint file loaded               Info   dir, entries
+//	data files loaded              Info   dir, files, records
+//	data file corrupted            Error  dir, fid, offset, err
+//	data file rotated              Info   dir, old_fid, new_fid
+//	merge started                  Info   dir, files, non_merge_fid, resumed
+//	merge finished                 Info   dir, files, kept_records, duration
+//	merge failed                   Error  dir, err
+//	merge files loaded             Info   dir, files
+//	merge files discarded          Warn   dir
+//	data files moved to cold dir   Info   dir, files
+//	tiering failed                 Error  dir, err
+//	db dropped                     Info   dir, files
+//	unfinished drop all resumed    Warn   dir
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// 日志字段名称
+const (
+	logKeyDir         = "dir"
+	logKeyErr         = "err"
+	logKeyFid         = "fid"
+	logKeyOffset      = "offset"
+	logKeyDuration    = "duration"
+	logKeyFiles       = "files"
+	logKeyDataFiles   = "data_files"
+	logKeyKeys        = "keys"
+	logKeySeqNo       = "seq_no"
+	logKeyEntries     = "entries"
+	logKeyRecords     = "records"
+	logKeyOldFid      = "old_fid"
+	logKeyNewFid      = "new_fid"
+	logKeyNonMergeFid = "non_merge_fid"
+	logKeyKeptRecords = "kept_records"
+	logKeyResumed     = "resumed"
+)
+
+// 默认的日志实现，丢弃所有日志
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,55 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"myRosedb/utils"
+	"os"
+	"sync"
+	"testing"
+)
+
+// *slog.Logger 可以直接作为 Logger 使用
+var _ Logger = slog.Default()
+
+// 记录所有日志事件，用于测试
+type recordLogger struct {
+	mu     sync.Mutex
+	events map[string][]any
+}
+
+func (l *recordLogger) log(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[msg] = args
+}
+
+func (l *recordLogger) Debug(msg string, args ...any) { l.log(msg, args...) }
+func (l *recordLogger) Info(msg string, args ...any)  { l.log(msg, args...) }
+func (l *recordLogger) Warn(msg string, args ...any)  { l.log(msg, args...) }
+func (l *recordLogger) Error(msg string, args ...any) { l.log(msg, args...) }
+
+func TestDB_Logger(t *testing.T) {
+	logger := &recordLogger{events: make(map[string][]any)}
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-logger")
+	opts.DirPath = dir
+	opts.DataFileSize = 4 * 1024
+	opts.Logger = logger
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+	assert.Contains(t, logger.events, "db opened")
+
+	for i := 0; i < 100; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(20))
+		assert.Nil(t, err)
+	}
+	assert.Contains(t, logger.events, "data file rotated")
+	assert.Equal(t, logKeyDir, logger.events["data file rotated"][0])
+
+	_, err = Open(opts)
+	assert.Equal(t, ErrDatabaseIsUsing, err)
+	assert.Equal(t, []any{logKeyDir, dir, logKeyErr, ErrDatabaseIsUsing}, logger.events["directory lock failed"])
+}
@@ -1,173 +1,448 @@
 package bitcask_go
 
 import (
-	"fmt"
+	"context"
 	"io"
 	"myRosedb/data"
+	"myRosedb/fio"
 	"myRosedb/utils"
 	"os"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
-	mergeDirName     = "-merge"
-	mergeFinishedKey = "merge.finished"
+	mergeDirName      = "-merge"
+	mergeFinishedKey  = "merge.finished" // 旧版本 merge 完成的标识，记录没有参与 merge 的最小文件 id
+	mergeRewrittenKey = "merge.rewritten"
+	mergeRemovedKey   = "merge.removed"
 )
 
 // Merger 清理无效数据，生成 Hint 文件
 func (db *DB) Merge() error {
+	return db.MergeCtx(context.Background())
+}
+
+// MergeCtx 与 Merge 相同，每处理一条数据前检查 ctx 是否已经被取消
+// 被取消时返回 ctx.Err()，已经处理完成的数据文件记录在检查点中，下一次 merge 会从检查点继续
+// 读写数据文件的速度受 MergeRateLimit 限制，进度可以通过 MergeStatus 获取
+//
+// 按照 MergeFileGarbageRatio 和 MergeMaxFiles 选择数据文件，每个数据文件中的有效数据被重写到文件 id 相同的新文件中，
+// 并为其生成只包含这些记录的 hint 文件，重启之后新的文件替换原来的数据文件
+func (db *DB) MergeCtx(ctx context.Context) (err error) {
 	// 如果数据库为空，则直接返回
 	if db.activeFile == nil {
 		return nil
 	}
+	mergeStart := time.Now()
+	defer func() {
+		if err != nil && err != ErrMergeIsProgress && err != ErrMergeRatioUnreached {
+			db.options.Logger.Error("merge failed", logKeyDir, db.options.DirPath, logKeyErr, err)
+		}
+	}()
 	db.mu.Lock()
 	// 如果 merge 正在进行当中，则直接返回
 	if db.isMerging {
 		db.mu.Unlock()
 		return ErrMergeIsProgress
 	}
-
-	// 查看可以 merge 的数据是否达到了阈值
-	totalSize, err := utils.DirSize(db.options.DirPath)
-	if err != nil {
+	if db.isTiering {
 		db.mu.Unlock()
-		return err
-	}
-	if float32(db.reclaimSize)/float32(totalSize) < db.options.DataFileMergeRatio {
-		db.mu.Unlock()
-		return ErrMergeRatioUnreached
+		return ErrTieringIsProgress
 	}
 
-	// 查看剩余空间容量食肉可以容纳 merge 之后的数据量
-	// todo
+	// 上一次没有完成的 merge 从检查点继续，不需要重新选择数据文件
+	mergePath := db.getMergePath()
+	checkpoint := db.resumableMergeCheckpoint(mergePath)
+	defer func() {
+		if checkpoint != nil {
+			checkpoint.close()
+		}
+	}()
+	var mergeFiles []*data.DataFile
+	var plan mergePlan
+	if checkpoint != nil {
+		plan = checkpoint.plan
+		for _, fid := range plan.Files {
+			mergeFiles = append(mergeFiles, db.olderFiles[fid])
+		}
+	} else {
+		if mergeFiles, err = db.planMerge(); err != nil {
+			db.mu.Unlock()
+			return err
+		}
+	}
 
 	db.isMerging = true
 	defer func() {
 		db.isMerging = false
 	}()
 
-	// 对当前活跃文件进行处理
-	// 将当前活跃文件持久化，并进行merge，在创建新的活跃文件
-	if err := db.activeFile.Sync(); err != nil {
-		db.mu.Unlock()
-		return err
+	if checkpoint == nil {
+		// 当前活跃文件参与 merge 时，将其转换为旧的数据文件，并打开新的活跃文件
+		if mergeFiles[len(mergeFiles)-1] == db.activeFile {
+			if err := db.rotateActiveFile(); err != nil {
+				db.mu.Unlock()
+				return err
+			}
+		}
+		plan = db.newMergePlan(mergeFiles)
 	}
 
-	// 将当前活跃文件转换为旧的数据文件
-	db.olderFiles[db.activeFile.FileID] = db.activeFile
-
-	// 打开新的活跃文件
-	if err := db.setActiveDataFile(); err != nil {
-		db.mu.Unlock()
-		return err
+	// 记录需要读取的数据量，用于估算 merge 的剩余时间
+	var mergeInputSize int64
+	var pendingFiles []*data.DataFile
+	for _, dataFile := range mergeFiles {
+		if checkpoint != nil {
+			if _, ok := checkpoint.done[dataFile.FileID]; ok {
+				continue
+			}
+		}
+		fileSize, err := dataFile.IoManager.Size()
+		if err != nil {
+			db.mu.Unlock()
+			return err
+		}
+		mergeInputSize += fileSize
+		pendingFiles = append(pendingFiles, dataFile)
 	}
-	// 这个活跃文件没有参与 merge，对它进行记录
-	// 记录最近没有参与 merge 的文件id
-	nonMergeFileId := db.activeFile.FileID
+	db.mergeProgress.start(len(mergeFiles), len(mergeFiles)-len(pendingFiles), mergeInputSize)
+	defer db.mergeProgress.finish()
 
-	// 	取出所有需要 merge 的文件
-	var mergeFiles []*data.DataFile
-	for _, file := range db.olderFiles {
-		mergeFiles = append(mergeFiles, file)
-	}
 	// 将锁释放，可以接受用户新的写入了
 	db.mu.Unlock()
+	db.options.Logger.Info("merge started",
+		logKeyDir, db.options.DirPath,
+		logKeyFiles, len(pendingFiles),
+		logKeyNonMergeFid, plan.NonMergeFileId,
+		logKeyResumed, checkpoint != nil,
+	)
 
-	// 将 merge 的文件从小到大进行排序，依次 merge
-	sort.Slice(mergeFiles, func(i, j int) bool {
-		return mergeFiles[i].FileID < mergeFiles[j].FileID
-	})
+	if checkpoint == nil {
+		// 如果目录存在，说明发生过merge，将其删除掉
+		if _, err := os.Stat(mergePath); err == nil {
+			if err := os.RemoveAll(mergePath); err != nil {
+				return err
+			}
+		}
+		// 新建一个 merge path 的目录
+		if err := os.MkdirAll(mergePath, os.ModePerm); err != nil {
+			return err
+		}
+		if checkpoint, err = createMergeCheckpoint(mergePath, plan); err != nil {
+			return err
+		}
+	}
 
-	mergePath := db.getMergePath()
-	// 如果目录存在，说明发生过merge，将其删除掉
-	if _, err := os.Stat(mergePath); err == nil {
-		if err := os.RemoveAll(mergePath); err != nil {
+	// 遍历处理每个数据文件，每处理完一个文件记录一次检查点
+	var mergeOutputSize int64
+	var keptRecords int
+	for _, dataFile := range pendingFiles {
+		kept, written, err := db.rewriteDataFile(ctx, dataFile, mergePath, dataFile.FileID > plan.MinUnmergedFid)
+		if err != nil {
+			return err
+		}
+		if err := checkpoint.append(mergeFileResult{Fid: dataFile.FileID, Kept: kept, Size: written}); err != nil {
 			return err
 		}
+		keptRecords += kept
+		mergeOutputSize += written
+		db.mergeProgress.fileDone()
 	}
-	// 新建一个 merge path 的目录
-	if err := os.MkdirAll(mergePath, os.ModePerm); err != nil {
-		return err
+
+	// 没有任何有效数据的文件直接删除
+	var rewritten, removed []uint32
+	for _, fid := range plan.Files {
+		if checkpoint.done[fid].Kept == 0 {
+			removed = append(removed, fid)
+		} else {
+			rewritten = append(rewritten, fid)
+		}
 	}
-	// 打开一个新的临时 bitcask 实例
-	mergeOptions := db.options
-	mergeOptions.DirPath = mergePath
-	// 不用每次都 sync，因为 merge 不一定成功，最后再一起Sycn，不会影响正确性
-	mergeOptions.SyncWrites = false
-	mergeDB, err := Open(mergeOptions)
+
+	// 重启之后被丢弃的记录中的序列号无法从数据文件中恢复，在 merge 生效之前保存当前的序列号
+	// 已经被删除的 key 不再记录历史版本，避免不断写入和删除不同的 key 时内存一直增长
+	db.mu.Lock()
+	err = db.saveSeqNo()
+	db.versions.Prune(func(key []byte) bool {
+		return db.index.Get(key) == nil
+	})
+	db.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
-	// 打开 hint 文件存储索引
-	hintFile, err := data.OpenHintFile(mergePath)
+	// 写表示 merge 完成的文件，记录重写和删除的文件 id
+	mergeFinishedFile, err := data.OpenMergeFinishedFile(mergePath)
 	if err != nil {
 		return err
 	}
-	// 遍历处理每个数据文件
+	defer func() {
+		_ = mergeFinishedFile.Close()
+	}()
+	for _, record := range []*data.LogRecord{
+		{Key: []byte(mergeRewrittenKey), Value: encodeFileIds(rewritten)},
+		{Key: []byte(mergeRemovedKey), Value: encodeFileIds(removed)},
+	} {
+		encRecord, _ := data.EncodeLogRecord(record)
+		if err := mergeFinishedFile.Write(encRecord); err != nil {
+			return err
+		}
+	}
+	if err := mergeFinishedFile.Sync(); err != nil {
+		return err
+	}
+
+	// 记录 merge 的耗时以及回收的数据量
+	db.metrics.mergeLatency.ObserveSince(mergeStart)
+	if mergeInputSize > mergeOutputSize {
+		db.metrics.mergeReclaimedBytes.Add(uint64(mergeInputSize - mergeOutputSize))
+	}
+	db.options.Logger.Info("merge finished",
+		logKeyDir, db.options.DirPath,
+		logKeyFiles, len(mergeFiles),
+		logKeyKeptRecords, keptRecords,
+		logKeyDuration, time.Since(mergeStart),
+	)
+	return nil
+}
+
+// 检查是否需要进行 merge，并选择需要 merge 的数据文件
+// 在访问此方法前必须持有互斥锁
+func (db *DB) planMerge() ([]*data.DataFile, error) {
+	// 查看可以 merge 的数据是否达到了阈值
+	totalSize, err := db.dataDirsSize()
+	if err != nil {
+		return nil, err
+	}
+	if float32(db.reclaimSize)/float32(totalSize) < db.options.DataFileMergeRatio {
+		return nil, ErrMergeRatioUnreached
+	}
+
+	// 查看剩余空间容量是否可以容纳 merge 之后的数据量
+	availableDiskSize, err := utils.AvailableDiskSize(db.options.DirPath)
+	if err != nil {
+		return nil, err
+	}
+	// 无效数据的统计可能大于目录中数据的实际大小，此时按照 0 计算，避免转换为 uint64 时溢出
+	mergedSize := totalSize - db.reclaimSize
+	if mergedSize < 0 {
+		mergedSize = 0
+	}
+	if uint64(mergedSize) >= availableDiskSize {
+		return nil, ErrNoEnoughSpaceForMerge
+	}
+
+	// 取出所有需要 merge 的文件
+	mergeFiles := db.pickMergeFiles()
+	if len(mergeFiles) == 0 {
+		return nil, ErrMergeRatioUnreached
+	}
+	return mergeFiles, nil
+}
+
+// 根据选择的数据文件生成 merge 的计划，需要在活跃文件转换之后调用
+// 在访问此方法前必须持有互斥锁
+func (db *DB) newMergePlan(mergeFiles []*data.DataFile) mergePlan {
+	plan := mergePlan{NonMergeFileId: db.activeFile.FileID}
+	merging := make(map[uint32]bool, len(mergeFiles))
 	for _, dataFile := range mergeFiles {
-		var offset int64 = 0
-		for {
-			logRecord, size, err := dataFile.ReadLogRecord(offset)
-			if err != nil {
-				// 如果没有更多内容可以获取，则返回
-				if err == io.EOF {
-					break
-				}
-				return err
+		plan.Files = append(plan.Files, dataFile.FileID)
+		merging[dataFile.FileID] = true
+	}
+	// 删除标记只有在更早的数据文件都参与了这次 merge 时才可以丢弃，否则重启之后更早的数据会重新生效
+	plan.MinUnmergedFid = plan.NonMergeFileId
+	for fid := range db.olderFiles {
+		if !merging[fid] && fid < plan.MinUnmergedFid {
+			plan.MinUnmergedFid = fid
+		}
+	}
+	return plan
+}
+
+// 按照 MergeFileGarbageRatio 和 MergeMaxFiles 选择需要 merge 的数据文件，按照文件 id 从小到大排列
+// 在访问此方法前必须持有互斥锁
+func (db *DB) pickMergeFiles() []*data.DataFile {
+	type candidate struct {
+		dataFile *data.DataFile
+		usage    fileUsage
+	}
+	var candidates []candidate
+	largeBatchFid, hasLargeBatch := db.minLargeBatchFid()
+	addCandidate := func(dataFile *data.DataFile) {
+		// 没有提交的 LargeBatch 写入的记录不在索引中，merge 时会被丢弃
+		if hasLargeBatch && dataFile.FileID >= largeBatchFid {
+			return
+		}
+		usage := db.fileUsageSnapshot(dataFile.FileID)
+		if usage.garbageRatio() >= float64(db.options.MergeFileGarbageRatio) {
+			candidates = append(candidates, candidate{dataFile, usage})
+		}
+	}
+	for _, dataFile := range db.olderFiles {
+		addCandidate(dataFile)
+	}
+	if db.activeFile.WriteOff > 0 {
+		addCandidate(db.activeFile)
+	}
+
+	// 只保留无效数据比例最高的 MergeMaxFiles 个文件
+	if db.options.MergeMaxFiles > 0 && len(candidates) > db.options.MergeMaxFiles {
+		sort.Slice(candidates, func(i, j int) bool {
+			ri, rj := candidates[i].usage.garbageRatio(), candidates[j].usage.garbageRatio()
+			if ri != rj {
+				return ri > rj
 			}
-			realKey, _ := parseLogRecordKey(logRecord.Key)
-			// 因为如果索引中有，那一定是有效的
-			// 为什么不直接取出索引中的每个数据，再写入新的文件当中呢
-			logRecordPos := db.index.Get(realKey)
-			// 把内存中的索引位置进行比较，如果有效则重写（写入merge）
-			if logRecordPos != nil &&
-				logRecordPos.Fid == dataFile.FileID &&
-				logRecordPos.Offset == offset {
-				// 清除事务标记，因为数据都是正确的，不需要事务号
-				logRecord.Key = logRecordKeyWithSeq(realKey, nonTransactionSeqNo)
-				pos, err := mergeDB.appendLogRecord(logRecord)
-				if err != nil {
-					return err
-				}
-				// 将当前（新的）位置索引写入 Hint 文件当中
-				if err := hintFile.WriteHintRecord(realKey, pos); err != nil {
-					return err
-				}
+			if candidates[i].usage.dead != candidates[j].usage.dead {
+				return candidates[i].usage.dead > candidates[j].usage.dead
 			}
-			// 递增 offset
-			offset += size
-		}
+			return candidates[i].dataFile.FileID < candidates[j].dataFile.FileID
+		})
+		candidates = candidates[:db.options.MergeMaxFiles]
 	}
 
-	// sync 保证持久化
-	if err := hintFile.Sync(); err != nil {
-		return err
+	mergeFiles := make([]*data.DataFile, 0, len(candidates))
+	for _, c := range candidates {
+		mergeFiles = append(mergeFiles, c.dataFile)
 	}
-	if err := mergeDB.Sync(); err != nil {
-		return err
+	sort.Slice(mergeFiles, func(i, j int) bool {
+		return mergeFiles[i].FileID < mergeFiles[j].FileID
+	})
+	return mergeFiles
+}
+
+// 将数据文件中的有效数据重写到 merge 目录中文件 id 相同的新文件中，并生成只包含这些记录的 hint 文件
+// keepTombstones 表示还有更早的数据文件没有参与这次 merge，需要保留已经删除的 key 的删除标记
+// 没有需要保留的记录时不生成任何文件，返回的 kept 为 0
+func (db *DB) rewriteDataFile(ctx context.Context, dataFile *data.DataFile, mergePath string, keepTombstones bool) (kept int, written int64, err error) {
+	// 从检查点继续时，上一次没有处理完的文件需要重新生成
+	for _, fileName := range []string{data.GetDataFileName(mergePath, dataFile.FileID), data.GetHintFileName(mergePath, dataFile.FileID)} {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return 0, 0, err
+		}
 	}
-	// 写表示 merge 完成的文件
-	mergeFinishedFile, err := data.OpenMergeFinishedFile(mergePath)
+	mergeFile, err := data.OpenActiveDataFile(mergePath, dataFile.FileID, fio.StandardFIO, 0)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
-	// 标识 merge 完成了哪一部分文件的merge
-	mergeFinRecord := &data.LogRecord{
-		Key: []byte(mergeFinishedKey),
-		// 比这个文件 id 小的，表示都参与过 merge
-		Value: []byte(strconv.Itoa(int(nonMergeFileId))),
+	db.configureFile(mergeFile)
+	hintFile, err := data.OpenDataHintFile(mergePath, dataFile.FileID)
+	if err != nil {
+		_ = mergeFile.Close()
+		return 0, 0, err
 	}
-	encRecord, _ := data.EncodeLogRecord(mergeFinRecord)
-	if err := mergeFinishedFile.Write(encRecord); err != nil {
-		return err
+	db.configureFile(hintFile)
+	defer func() {
+		_ = hintFile.Close()
+		_ = mergeFile.Close()
+		if err == nil && kept == 0 {
+			_ = os.Remove(data.GetDataFileName(mergePath, dataFile.FileID))
+			_ = os.Remove(data.GetHintFileName(mergePath, dataFile.FileID))
+		}
+	}()
+
+	var offset int64 = 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+		logRecord, size, err := dataFile.ReadLogRecord(offset)
+		if err != nil {
+			// 如果没有更多内容可以获取，则返回
+			if err == io.EOF {
+				break
+			}
+			return 0, 0, err
+		}
+		db.mergeProgress.addRead(size)
+		if err := db.mergeLimiter.WaitN(ctx, size); err != nil {
+			return 0, 0, err
+		}
+		// 文件尾不是用户数据，不需要重写
+		if logRecord.Type == data.LogRecordFileFooter {
+			offset += size
+			continue
+		}
+
+		realKey, txnSeqNo := parseLogRecordKey(logRecord.Key)
+		var keep bool
+		switch logRecord.Type {
+		case data.LogRecordTxnFinished:
+			// 重写之后的数据不需要事务号，但是更早的数据文件没有参与 merge 时，其中可能还有这个事务的数据
+			// 事务的数据都在事务完成的标识之前写入，所以更早的数据文件都参与了 merge 时才可以丢弃
+			keep = keepTombstones
+		case data.LogRecordDeleted:
+			// 删除之后又被重新写入的 key，之前的数据已经被覆盖，不需要删除标记
+			keep = keepTombstones && db.index.Get(realKey) == nil
+		case data.LogRecordRangeDeleted:
+			// 范围删除标记需要保留到更早的数据文件都被 merge 之后
+			// 保留历史版本时，范围内的 key 的旧版本会被重写，范围删除标记也要一直保留
+			keep = keepTombstones || db.options.MergeKeepVersions > 0
+		default:
+			// 把内存中的索引位置进行比较，如果有效则重写
+			logRecordPos := db.index.Get(realKey)
+			keep = logRecordPos != nil && logRecordPos.Fid == dataFile.FileID && logRecordPos.Offset == offset
+		}
+		// 需要保留的历史版本也要重写
+		if !keep && logRecord.Type != data.LogRecordTxnFinished && db.options.MergeKeepVersions > 0 {
+			pos := &data.LogRecordPos{Fid: dataFile.FileID, Offset: offset}
+			keep = db.versions.Contains(realKey, pos, db.options.MergeKeepVersions)
+		}
+		if keep {
+			// 清除事务标记，因为数据都是正确的，不需要事务号，事务完成的标识保留原来的事务号
+			hintKey := realKey
+			if logRecord.Type == data.LogRecordTxnFinished {
+				hintKey = logRecord.Key
+			} else {
+				// LargeBatch 中的记录去掉事务号之后，版本信息中改为提交时分配的序列号
+				if txnSeqNo != nonTransactionSeqNo && logRecord.Version != nil {
+					db.mu.RLock()
+					logRecord.Version.Seq = db.recordSeq(logRecord)
+					db.mu.RUnlock()
+				}
+				logRecord.Key = logRecordKeyWithSeq(realKey, nonTransactionSeqNo)
+			}
+			encRecord, _, err := mergeFile.EncodeLogRecord(logRecord)
+			if err != nil {
+				return 0, 0, err
+			}
+			pos := &data.LogRecordPos{Fid: dataFile.FileID, Offset: mergeFile.WriteOff, Size: uint32(len(encRecord))}
+			if err := db.mergeLimiter.WaitN(ctx, int64(len(encRecord))); err != nil {
+				return 0, 0, err
+			}
+			if err := mergeFile.Write(encRecord); err != nil {
+				return 0, 0, err
+			}
+			// 将新的位置索引写入 Hint 文件当中
+			if err := hintFile.WriteHintRecordOf(logRecord, hintKey, pos); err != nil {
+				return 0, 0, err
+			}
+			kept++
+			db.mergeProgress.addRecord(true, int64(len(encRecord)))
+		} else {
+			db.mergeProgress.addRecord(false, 0)
+		}
+		// 递增 offset
+		offset += size
 	}
-	if err := mergeFinishedFile.Sync(); err != nil {
-		return err
+	if kept == 0 {
+		return 0, 0, nil
 	}
-	return nil
+
+	// sync 保证持久化
+	if err := mergeFile.WriteFooter(); err != nil {
+		return 0, 0, err
+	}
+	if err := mergeFile.Sync(); err != nil {
+		return 0, 0, err
+	}
+	if err := hintFile.Sync(); err != nil {
+		return 0, 0, err
+	}
+	return kept, mergeFile.WriteOff, nil
 }
 
 // 拿到目前数据目录路径，在该目录中添加 merge 文件夹
@@ -176,7 +451,6 @@ func (db *DB) getMergePath() string {
 	dir := filepath.Dir(filepath.Clean(db.options.DirPath))
 	// Base returns the last element of path
 	base := filepath.Base(db.options.DirPath)
-	fmt.Println(filepath.Join(dir, base+mergeDirName))
 	return filepath.Join(dir, base+mergeDirName)
 }
 
@@ -187,21 +461,90 @@ func (db *DB) loadMergeFile() error {
 	if _, err := os.Stat(mergePath); os.IsNotExist(err) {
 		return nil
 	}
-	// 如果有 merge 目录的话进行删除，因为要重写生成 merge
+	// 查找标识 merge 完成的文件，判断 merge 是否处理完成了
+	// 没有完成但是有检查点的 merge 目录保留下来，下一次 merge 时从检查点继续
+	if _, err := os.Stat(filepath.Join(mergePath, data.MergeFinishedFileName)); os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(mergePath, data.MergeCheckpointName)); err == nil {
+			return nil
+		}
+		db.options.Logger.Warn("merge files discarded", logKeyDir, db.options.DirPath)
+		return os.RemoveAll(mergePath)
+	}
+	// merge 之后的文件加载完成之后删除 merge 目录
 	defer func() {
 		_ = os.RemoveAll(mergePath)
 	}()
+	rewritten, removed, legacy, err := readMergeFinishedFile(mergePath)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		return db.loadLegacyMergeFile(mergePath)
+	}
+
+	// 旧版本 merge 生成的 hint 文件中的位置可能已经失效，之后所有的数据文件都按照文件 id 的顺序加载
+	for _, fileName := range []string{data.HintFileName, data.MergeFinishedFileName} {
+		if err := os.Remove(filepath.Join(db.options.DirPath, fileName)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// 先删除原来的数据文件，再将 merge 之后的文件移动到 DirPath 中，中途崩溃之后重启可以继续完成
+	for _, fileId := range rewritten {
+		srcPath := data.GetDataFileName(mergePath, fileId)
+		if _, err := os.Stat(srcPath); err == nil {
+			if err := db.removeDataFile(fileId); err != nil {
+				return err
+			}
+			if err := os.Rename(srcPath, data.GetDataFileName(db.options.DirPath, fileId)); err != nil {
+				return err
+			}
+		}
+		hintPath := data.GetHintFileName(mergePath, fileId)
+		if _, err := os.Stat(hintPath); err == nil {
+			if err := os.Rename(hintPath, data.GetHintFileName(db.options.DirPath, fileId)); err != nil {
+				return err
+			}
+		}
+		// 同一个文件 id 会被 merge 之后的文件替换，缓存中的数据需要失效
+		db.valueCache.RemoveFile(fileId)
+	}
+	for _, fileId := range removed {
+		if err := db.removeDataFile(fileId); err != nil {
+			return err
+		}
+		db.valueCache.RemoveFile(fileId)
+	}
+
+	db.options.Logger.Info("merge files loaded",
+		logKeyDir, db.options.DirPath,
+		logKeyFiles, len(rewritten)+len(removed),
+	)
+	return nil
+}
+
+// 删除数据文件以及对应的 hint 文件，数据文件可能在任意一个存放数据文件的目录中
+func (db *DB) removeDataFile(fileId uint32) error {
+	fileNames := []string{data.GetHintFileName(db.options.DirPath, fileId)}
+	for _, dirPath := range db.dataDirs() {
+		fileNames = append(fileNames, data.GetDataFileName(dirPath, fileId))
+	}
+	for _, fileName := range fileNames {
+		if err := os.Remove(fileName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// 加载旧版本的 merge 数据目录，merge 之后的文件替换比 nonMergeFileId 更小的所有数据文件
+func (db *DB) loadLegacyMergeFile(mergePath string) error {
 	dirEntries, err := os.ReadDir(mergePath)
 	if err != nil {
 		return err
 	}
-	// 查找标识 merge 完成的文件，判断 merge 是否处理完成了
-	var mergeFinished bool
 	var mergeFileNames []string
 	for _, entry := range dirEntries {
-		if entry.Name() == data.MergeFinishedFileName {
-			mergeFinished = true
-		}
 		if entry.Name() == data.SeqNoFileName {
 			continue
 		}
@@ -211,26 +554,19 @@ func (db *DB) loadMergeFile() error {
 		mergeFileNames = append(mergeFileNames, entry.Name())
 	}
 
-	// 如果没有 merge 完成，则返回
-	if !mergeFinished {
-		return nil
-	}
-
-	//
 	nonMergeFileId, err := db.getNonMergeFileID(mergePath)
 	if err != nil {
 		return err
 	}
 	// 删除旧的数据文件，删除比 nonMergeFileId 更小的 id 文件
+	// 旧的数据文件可能已经被迁移到了冷存储目录
 	var fileId uint32 = 0
 	for ; fileId < nonMergeFileId; fileId++ {
-		fileName := data.GetDataFileName(db.options.DirPath, fileId)
-		// 如果数据文件存在则删掉
-		if _, err := os.Stat(fileName); err == nil {
-			if err := os.Remove(fileName); err != nil {
-				return err
-			}
+		if err := db.removeDataFile(fileId); err != nil {
+			return err
 		}
+		// 同一个文件 id 会被 merge 之后的文件替换，缓存中的数据需要失效
+		db.valueCache.RemoveFile(fileId)
 	}
 
 	// 将新的数据文件移动到数据文件目录当中
@@ -242,9 +578,75 @@ func (db *DB) loadMergeFile() error {
 			return err
 		}
 	}
+	db.options.Logger.Info("merge files loaded",
+		logKeyDir, db.options.DirPath,
+		logKeyNonMergeFid, nonMergeFileId,
+		logKeyFiles, len(mergeFileNames),
+	)
 	return nil
 }
 
+// 读取 merge 完成的文件中记录的重写和删除的文件 id，legacy 表示是旧版本 merge 生成的文件
+func readMergeFinishedFile(mergePath string) (rewritten, removed []uint32, legacy bool, err error) {
+	mergeFinishedFile, err := data.OpenMergeFinishedFile(mergePath)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer func() {
+		_ = mergeFinishedFile.Close()
+	}()
+
+	var offset int64
+	for {
+		record, size, err := mergeFinishedFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, nil, false, err
+		}
+		switch string(record.Key) {
+		case mergeFinishedKey:
+			return nil, nil, true, nil
+		case mergeRewrittenKey:
+			if rewritten, err = decodeFileIds(record.Value); err != nil {
+				return nil, nil, false, err
+			}
+		case mergeRemovedKey:
+			if removed, err = decodeFileIds(record.Value); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		offset += size
+	}
+	return rewritten, removed, false, nil
+}
+
+// 将文件 id 列表编码为以逗号分隔的字符串
+func encodeFileIds(fileIds []uint32) []byte {
+	ids := make([]string, 0, len(fileIds))
+	for _, fid := range fileIds {
+		ids = append(ids, strconv.FormatUint(uint64(fid), 10))
+	}
+	return []byte(strings.Join(ids, ","))
+}
+
+func decodeFileIds(buf []byte) ([]uint32, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	ids := strings.Split(string(buf), ",")
+	fileIds := make([]uint32, 0, len(ids))
+	for _, id := range ids {
+		fid, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		fileIds = append(fileIds, uint32(fid))
+	}
+	return fileIds, nil
+}
+
 func (db *DB) getNonMergeFileID(mergePath string) (uint32, error) {
 	mergeFinishedFile, err := data.OpenMergeFinishedFile(mergePath)
 	if err != nil {
@@ -274,9 +676,11 @@ func (db *DB) loadIndexFromHintFile() error {
 	if err != nil {
 		return err
 	}
+	db.configureFile(hintFile)
 
 	// 读取文件中的索引（hint采取的也是数据追加的方式，和读取数据文件方法类似）
 	var offset int64 = 0
+	var entries int
 	for {
 		logRecord, size, err := hintFile.ReadLogRecord(offset)
 		if err != nil {
@@ -289,6 +693,62 @@ func (db *DB) loadIndexFromHintFile() error {
 		pos := data.DecodeLogRecordPos(logRecord.Value)
 		db.index.Put(logRecord.Key, pos)
 		offset += size
+		entries++
 	}
+	db.options.Logger.Info("hint file loaded", logKeyDir, db.options.DirPath, logKeyEntries, entries)
 	return nil
 }
+
+// 从数据文件对应的 hint 文件中加载索引，hint 文件不存在时返回 false
+// merge 时保留的事务完成的标识交给 finishTxn 处理
+func (db *DB) loadIndexFromDataHintFile(fileId uint32, updateIndex func(*data.LogRecord, *data.LogRecordPos), finishTxn func(uint64, *data.RecordVersion, *data.LogRecordPos)) (int, bool, error) {
+	hintFileName := data.GetHintFileName(db.options.DirPath, fileId)
+	if _, err := os.Stat(hintFileName); os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	hintFile, err := data.OpenDataHintFile(db.options.DirPath, fileId)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = hintFile.Close()
+	}()
+	db.configureFile(hintFile)
+
+	var offset int64 = 0
+	var entries int
+	for {
+		logRecord, size, err := hintFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, false, err
+		}
+		pos := data.DecodeLogRecordPos(logRecord.Value)
+		logRecord.Value = nil
+		if logRecord.Type == data.LogRecordTxnFinished {
+			_, seqNo := parseLogRecordKey(logRecord.Key)
+			finishTxn(seqNo, logRecord.Version, pos)
+			offset += size
+			entries++
+			continue
+		}
+		// 范围删除标记的终点只保存在数据文件中
+		if logRecord.Type == data.LogRecordRangeDeleted {
+			dataFile := db.olderFiles[fileId]
+			if dataFile == nil {
+				return 0, false, ErrDataFileNotFound
+			}
+			rangeRecord, _, err := dataFile.ReadLogRecord(pos.Offset)
+			if err != nil {
+				return 0, false, err
+			}
+			logRecord.Value = rangeRecord.Value
+		}
+		updateIndex(logRecord, pos)
+		offset += size
+		entries++
+	}
+	return entries, true, nil
+}
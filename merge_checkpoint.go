@@ -0,0 +1,162 @@
+package bitcask_go
+
+import (
+	"encoding/json"
+	"io"
+	"myRosedb/data"
+	"os"
+	"path/filepath"
+)
+
+const (
+	mergePlanKey       = "merge.plan"
+	mergeCheckpointKey = "merge.checkpoint"
+)
+
+// merge 的计划，开始 merge 时写入检查点文件
+type mergePlan struct {
+	Files          []uint32 `json:"files"`            // 需要 merge 的数据文件，按照文件 id 从小到大排列
+	NonMergeFileId uint32   `json:"non_merge_fid"`    // 开始 merge 时的活跃文件 id
+	MinUnmergedFid uint32   `json:"min_unmerged_fid"` // 没有参与 merge 的最小文件 id，决定是否需要保留删除标记
+}
+
+// 一个数据文件处理完成之后的结果
+type mergeFileResult struct {
+	Fid  uint32 `json:"fid"`
+	Kept int    `json:"kept"` // 重写的记录数量
+	Size int64  `json:"size"` // merge 之后的文件大小，0 表示没有有效数据，文件会被删除
+}
+
+// merge 的检查点，记录 merge 的计划以及已经处理完成的数据文件
+// 每个数据文件重写完成并持久化之后才追加一条记录，进程中途退出之后，下一次 merge 从检查点继续
+type mergeCheckpoint struct {
+	file *data.DataFile
+	plan mergePlan
+	done map[uint32]mergeFileResult
+}
+
+// 在 merge 目录中创建检查点文件，并写入 merge 的计划
+func createMergeCheckpoint(mergePath string, plan mergePlan) (*mergeCheckpoint, error) {
+	file, err := data.OpenMergeCheckpointFile(mergePath)
+	if err != nil {
+		return nil, err
+	}
+	c := &mergeCheckpoint{file: file, plan: plan, done: make(map[uint32]mergeFileResult)}
+	if err := c.write(mergePlanKey, plan); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// 打开 merge 目录中已有的检查点文件，不存在或者没有完整的计划时返回 nil
+// 末尾没有写完整的记录会被丢弃
+func openMergeCheckpoint(mergePath string) (*mergeCheckpoint, error) {
+	if _, err := os.Stat(filepath.Join(mergePath, data.MergeCheckpointName)); err != nil {
+		return nil, nil
+	}
+	file, err := data.OpenMergeCheckpointFile(mergePath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &mergeCheckpoint{file: file, done: make(map[uint32]mergeFileResult)}
+	var offset int64
+	var hasPlan bool
+	for {
+		record, size, err := file.ReadLogRecord(offset)
+		if err != nil {
+			// 最后一条记录可能没有写完整，从这里开始继续追加
+			if err != io.EOF && err != data.ErrInvalidCRC && err != io.ErrUnexpectedEOF {
+				_ = file.Close()
+				return nil, err
+			}
+			break
+		}
+		switch string(record.Key) {
+		case mergePlanKey:
+			if err := json.Unmarshal(record.Value, &c.plan); err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+			hasPlan = true
+		case mergeCheckpointKey:
+			var result mergeFileResult
+			if err := json.Unmarshal(record.Value, &result); err != nil {
+				_ = file.Close()
+				return nil, err
+			}
+			c.done[result.Fid] = result
+		}
+		offset += size
+	}
+	if !hasPlan {
+		_ = file.Close()
+		return nil, nil
+	}
+	if err := file.Truncate(offset); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// 记录一个数据文件处理完成
+func (c *mergeCheckpoint) append(result mergeFileResult) error {
+	if err := c.write(mergeCheckpointKey, result); err != nil {
+		return err
+	}
+	c.done[result.Fid] = result
+	return nil
+}
+
+func (c *mergeCheckpoint) write(key string, value any) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	encRecord, _ := data.EncodeLogRecord(&data.LogRecord{Key: []byte(key), Value: buf})
+	if err := c.file.Write(encRecord); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
+
+func (c *mergeCheckpoint) close() {
+	_ = c.file.Close()
+}
+
+// 打开可以继续进行的 merge 检查点，没有检查点或者检查点已经失效时返回 nil
+// 计划中的数据文件都必须仍然存在，已经处理完成的文件的输出必须完整
+// 在访问此方法前必须持有互斥锁
+func (db *DB) resumableMergeCheckpoint(mergePath string) *mergeCheckpoint {
+	c, err := openMergeCheckpoint(mergePath)
+	if err != nil || c == nil {
+		return nil
+	}
+	valid := c.plan.NonMergeFileId <= db.activeFile.FileID
+	for _, fid := range c.plan.Files {
+		if _, ok := db.olderFiles[fid]; !ok || fid >= c.plan.NonMergeFileId {
+			valid = false
+			break
+		}
+		result, ok := c.done[fid]
+		if !ok || result.Size == 0 {
+			continue
+		}
+		info, err := os.Stat(data.GetDataFileName(mergePath, fid))
+		if err != nil || info.Size() != result.Size {
+			valid = false
+			break
+		}
+		if _, err := os.Stat(data.GetHintFileName(mergePath, fid)); err != nil {
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		c.close()
+		return nil
+	}
+	return c
+}
@@ -0,0 +1,87 @@
+package bitcask_go
+
+import (
+	"sync"
+	"time"
+)
+
+// MergeStatus merge 的进度，merge 结束之后保留最近一次的结果
+type MergeStatus struct {
+	Running        bool          // 是否正在进行 merge
+	StartTime      time.Time     // 最近一次 merge 开始的时间，没有进行过 merge 时为零值
+	TotalFiles     int           // 需要处理的数据文件数量
+	FilesDone      int           // 已经处理完成的数据文件数量
+	TotalBytes     int64         // 需要读取的数据文件的总大小，从检查点继续时不包括已经处理完成的文件
+	BytesRead      int64         // 已经读取的数据量
+	BytesCopied    int64         // 写入 merge 之后的文件的数据量
+	RecordsKept    uint64        // 被重写的记录数量
+	RecordsDropped uint64        // 被丢弃的无效记录数量
+	ETA            time.Duration // 按照已经读取的速度估算的剩余时间，没有在进行 merge 时为 0
+}
+
+// 记录 merge 的进度，并发安全
+type mergeProgress struct {
+	mu     *sync.Mutex
+	status MergeStatus
+}
+
+func newMergeProgress() *mergeProgress {
+	return &mergeProgress{mu: new(sync.Mutex)}
+}
+
+// 开始 merge，从检查点继续时 filesDone 为已经处理完成的文件数量，totalBytes 只包括剩余的文件
+func (p *mergeProgress) start(totalFiles, filesDone int, totalBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = MergeStatus{
+		Running:    true,
+		StartTime:  time.Now(),
+		TotalFiles: totalFiles,
+		FilesDone:  filesDone,
+		TotalBytes: totalBytes,
+	}
+}
+
+// 记录读取的 n 个字节
+func (p *mergeProgress) addRead(n int64) {
+	p.mu.Lock()
+	p.status.BytesRead += n
+	p.mu.Unlock()
+}
+
+// 记录一条被处理的记录，copied 为重写之后写入的字节数
+func (p *mergeProgress) addRecord(kept bool, copied int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if kept {
+		p.status.RecordsKept++
+		p.status.BytesCopied += copied
+	} else {
+		p.status.RecordsDropped++
+	}
+}
+
+func (p *mergeProgress) fileDone() {
+	p.mu.Lock()
+	p.status.FilesDone++
+	p.mu.Unlock()
+}
+
+func (p *mergeProgress) finish() {
+	p.mu.Lock()
+	p.status.Running = false
+	p.mu.Unlock()
+}
+
+// MergeStatus 返回正在进行或者最近一次 merge 的进度
+func (db *DB) MergeStatus() MergeStatus {
+	db.mergeProgress.mu.Lock()
+	status := db.mergeProgress.status
+	db.mergeProgress.mu.Unlock()
+
+	if status.Running && status.BytesRead > 0 && status.TotalBytes > status.BytesRead {
+		elapsed := time.Since(status.StartTime)
+		status.ETA = time.Duration(float64(elapsed) * float64(status.TotalBytes-status.BytesRead) / float64(status.BytesRead))
+	}
+	return status
+}
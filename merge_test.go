@@ -1,11 +1,15 @@
 package bitcask_go
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"myRosedb/data"
 	"myRosedb/utils"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 )
 
 // 没有任何数据的情况下进行 merge
@@ -201,3 +205,346 @@ func TestDB_Merge5(t *testing.T) {
 		assert.NotNil(t, val)
 	}
 }
+
+// merge 过程中 ctx 被取消
+func TestDB_MergeCtx(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-ctx")
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.NotNil(t, db)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.MergeCtx(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	// 取消之后可以再次进行 merge
+	err = db.Merge()
+	assert.Nil(t, err)
+}
+
+// 只 merge 无效数据比例最高的文件
+func TestDB_MergeSelective(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-selective")
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.MergeFileGarbageRatio = 0.5
+	opts.MergeMaxFiles = 1
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	// 覆盖第一个文件中的所有数据
+	var overwritten [][]byte
+	for i := 0; i < 1000; i++ {
+		if db.index.Get(utils.GetTestKey(i)).Fid == 0 {
+			overwritten = append(overwritten, utils.GetTestKey(i))
+		}
+	}
+	for _, key := range overwritten {
+		err := db.Put(key, []byte("new value"))
+		assert.Nil(t, err)
+	}
+	stats, err := db.DataFileStats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), stats[0].Fid)
+	assert.Equal(t, int64(0), stats[0].LiveBytes)
+	assert.True(t, stats[0].DeadBytes > 0)
+	fileNum := len(stats)
+
+	err = db.Merge()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 第一个文件中没有有效数据，被直接删除，其他文件不变
+	// 调大文件大小，之后的写入都在同一个活跃文件中
+	opts.DataFileSize = 1024 * 1024
+	opts.MergeFileGarbageRatio = 0.1
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	_, err = os.Stat(data.GetDataFileName(dir, 0))
+	assert.True(t, os.IsNotExist(err))
+	stats, err = db2.DataFileStats()
+	assert.Nil(t, err)
+	assert.Equal(t, fileNum-1, len(stats))
+	for _, key := range overwritten {
+		val, err := db2.Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new value"), val)
+	}
+
+	// 删除一个较早的文件中的部分数据，删除标记写入活跃文件
+	var deleted [][]byte
+	for i := 0; i < 1000 && len(deleted) < 10; i++ {
+		if db2.index.Get(utils.GetTestKey(i)).Fid == 1 {
+			deleted = append(deleted, utils.GetTestKey(i))
+		}
+	}
+	for _, key := range deleted {
+		err := db2.Delete(key)
+		assert.Nil(t, err)
+	}
+	// 活跃文件中的无效数据比例最高，只有它会被 merge，其中的删除标记需要保留
+	activeFid := db2.activeFile.FileID
+	for i := 0; i < 100; i++ {
+		err := db2.Put([]byte("hot"), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	hot := []byte("hot value")
+	err = db2.Put([]byte("hot"), hot)
+	assert.Nil(t, err)
+	assert.Equal(t, activeFid, db2.activeFile.FileID)
+	err = db2.Merge()
+	assert.Nil(t, err)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	_, err = os.Stat(data.GetHintFileName(dir, activeFid))
+	assert.Nil(t, err)
+	for _, key := range deleted {
+		_, err := db3.Get(key)
+		assert.Equal(t, ErrKeyNotFound, err)
+	}
+	val, err := db3.Get([]byte("hot"))
+	assert.Nil(t, err)
+	assert.Equal(t, hot, val)
+	assert.Equal(t, uint(1000-len(deleted)+1), db3.Stat().KeyNum)
+	_, err = db3.VerifyFiles()
+	assert.Nil(t, err)
+}
+
+// 事务完成的标识所在的文件被 merge，而事务的数据在没有参与 merge 的更早的文件中
+func TestDB_MergeSelectiveTxn(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-selective-txn")
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.MergeFileGarbageRatio = 0.5
+	opts.MergeMaxFiles = 1
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	lb := db.NewLargeBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, lb.Put([]byte("txn"), []byte("committed")))
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	_, err = lb.CommitWithSeq()
+	assert.Nil(t, err)
+	finFid := db.activeFile.FileID
+	assert.Equal(t, uint32(0), db.index.Get([]byte("txn")).Fid)
+	assert.True(t, finFid > 0)
+
+	// 覆盖事务完成的标识所在的文件中的所有数据，只有这个文件会被 merge
+	db.mu.Lock()
+	assert.Nil(t, db.rotateActiveFile())
+	db.mu.Unlock()
+	for i := 0; i < 1000; i++ {
+		if db.index.Get(utils.GetTestKey(i)).Fid == finFid {
+			err := db.Put(utils.GetTestKey(i), []byte("new value"))
+			assert.Nil(t, err)
+		}
+	}
+	err = db.Merge()
+	assert.Nil(t, err)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	_, err = os.Stat(data.GetHintFileName(dir, finFid))
+	assert.Nil(t, err)
+	val, err := db2.Get([]byte("txn"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("committed"), val)
+	assert.Equal(t, uint(1001), db2.Stat().KeyNum)
+}
+
+// 无效数据的统计大于数据目录的大小时，不能误判为磁盘空间不足
+func TestDB_MergeReclaimSizeExceedsTotal(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-reclaim")
+	opts.DirPath = dir
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put([]byte("a"), []byte("1")))
+	totalSize, err := db.dataDirsSize()
+	assert.Nil(t, err)
+	db.mu.Lock()
+	db.reclaimSize = totalSize + 1024
+	db.mu.Unlock()
+	assert.Nil(t, db.Merge())
+}
+
+// 保留历史版本时，被范围删除的 key 的旧版本会被重写，范围删除标记也要保留
+func TestDB_MergeKeepVersionsRangeDeleted(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-range-versions")
+	opts.DirPath = dir
+	opts.DataFileMergeRatio = 0
+	opts.HistoryVersions = 2
+	opts.MergeKeepVersions = 2
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put([]byte("b"), []byte("1")))
+	assert.Nil(t, db.DeleteRange([]byte("a"), []byte("c")))
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+
+	db2, err := Open(opts)
+	defer destroyDB(db2)
+	assert.Nil(t, err)
+	_, err = db2.Get([]byte("b"))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+// merge 限速以及进度
+func TestDB_MergeStatus(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-status")
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.MergeRateLimit = 256 * 1024
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.False(t, db.MergeStatus().Running)
+
+	// 读写一共超过 300KB，限速之后至少需要 0.1 秒（令牌桶初始有 1 秒的令牌）
+	done := make(chan error)
+	go func() {
+		done <- db.Merge()
+	}()
+	var sawRunning bool
+	for !sawRunning {
+		select {
+		case err := <-done:
+			t.Fatalf("merge finished before status was observed: %v", err)
+		default:
+		}
+		status := db.MergeStatus()
+		sawRunning = status.Running && status.BytesRead > 0
+		time.Sleep(time.Millisecond)
+	}
+	assert.Nil(t, <-done)
+
+	status := db.MergeStatus()
+	assert.False(t, status.Running)
+	assert.Equal(t, status.TotalFiles, status.FilesDone)
+	assert.Equal(t, status.TotalBytes, status.BytesRead)
+	assert.Equal(t, uint64(500), status.RecordsKept)
+	assert.Equal(t, uint64(1000), status.RecordsDropped)
+	assert.True(t, status.BytesCopied > 0)
+	assert.Equal(t, time.Duration(0), status.ETA)
+}
+
+// merge 中途被取消之后，重启并从检查点继续
+func TestDB_MergeResume(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-merge-resume")
+	opts.DataFileSize = 32 * 1024
+	opts.DataFileMergeRatio = 0
+	opts.MergeRateLimit = 128 * 1024
+	opts.DirPath = dir
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		err := db.Put(utils.GetTestKey(i), utils.RandomValue(128))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 500; i++ {
+		err := db.Delete(utils.GetTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// 处理完两个文件之后取消
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- db.MergeCtx(ctx)
+	}()
+	for db.MergeStatus().FilesDone < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	status := db.MergeStatus()
+	assert.True(t, status.FilesDone < status.TotalFiles)
+	err = db.Close()
+	assert.Nil(t, err)
+
+	// 重启之后检查点仍然存在，merge 只处理剩余的文件
+	opts.MergeRateLimit = 0
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(db2.getMergePath(), data.MergeCheckpointName))
+	assert.Nil(t, err)
+	var totalSize int64
+	stats, err := db2.DataFileStats()
+	assert.Nil(t, err)
+	for _, stat := range stats {
+		totalSize += stat.Size
+	}
+	err = db2.Merge()
+	assert.Nil(t, err)
+	resumed := db2.MergeStatus()
+	assert.Equal(t, status.TotalFiles, resumed.TotalFiles)
+	assert.Equal(t, resumed.TotalFiles, resumed.FilesDone)
+	assert.True(t, resumed.TotalBytes < totalSize)
+	err = db2.Close()
+	assert.Nil(t, err)
+
+	db3, err := Open(opts)
+	defer destroyDB(db3)
+	assert.Nil(t, err)
+	_, err = os.Stat(db3.getMergePath())
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, uint(500), db3.Stat().KeyNum)
+	for i := 0; i < 1000; i++ {
+		_, err := db3.Get(utils.GetTestKey(i))
+		if i < 500 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+		}
+	}
+	_, err = db3.VerifyFiles()
+	assert.Nil(t, err)
+}
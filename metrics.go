@@ -0,0 +1,119 @@
+package bitcask_go
+
+import (
+	"io"
+	"myRosedb/cache"
+	"myRosedb/metrics"
+)
+
+// 存储引擎内部使用的指标，所有字段都是并发安全的
+type dbMetrics struct {
+	putLatency          *metrics.Histogram // Put 耗时
+	getLatency          *metrics.Histogram // Get 耗时
+	deleteLatency       *metrics.Histogram // Delete 耗时
+	bytesWritten        metrics.Counter    // 累计写入数据文件的字节数
+	syncCount           metrics.Counter    // 累计 fsync 次数
+	syncLatency         *metrics.Histogram // fsync 耗时
+	fileRotations       metrics.Counter    // 活跃文件转换为旧的数据文件的次数
+	mergeLatency        *metrics.Histogram // 完成一次 merge 的耗时
+	mergeReclaimedBytes metrics.Counter    // merge 累计回收的字节数
+	iteratorCount       metrics.Counter    // 累计创建的迭代器数量
+}
+
+func newDBMetrics() *dbMetrics {
+	return &dbMetrics{
+		putLatency:    metrics.NewHistogram(metrics.DefaultLatencyBuckets),
+		getLatency:    metrics.NewHistogram(metrics.DefaultLatencyBuckets),
+		deleteLatency: metrics.NewHistogram(metrics.DefaultLatencyBuckets),
+		syncLatency:   metrics.NewHistogram(metrics.DefaultLatencyBuckets),
+		mergeLatency:  metrics.NewHistogram(metrics.DefaultLatencyBuckets),
+	}
+}
+
+// Metrics 存储引擎运行指标的快照
+type Metrics struct {
+	PutLatency          metrics.HistogramSnapshot // Put 耗时分布
+	GetLatency          metrics.HistogramSnapshot // Get 耗时分布
+	DeleteLatency       metrics.HistogramSnapshot // Delete 耗时分布
+	BytesWritten        uint64                    // 累计写入数据文件的字节数
+	SyncCount           uint64                    // 累计 fsync 次数
+	SyncLatency         metrics.HistogramSnapshot // fsync 耗时分布
+	FileRotations       uint64                    // 数据文件转换的次数
+	MergeLatency        metrics.HistogramSnapshot // merge 耗时分布
+	MergeReclaimedBytes uint64                    // merge 累计回收的字节数
+	IteratorCount       uint64                    // 累计创建的迭代器数量
+	KeyNum              uint                      // Key 的总数量
+	ReclaimableSize     int64                     // 可以进行 merge 回收的数据量
+	ValueCache          cache.Stats               // value 缓存的命中情况，没有开启缓存时为零值
+}
+
+// Metrics 返回存储引擎当前的运行指标
+// 与 Stat 不同，这个方法不会遍历数据目录，可以被频繁调用
+func (db *DB) Metrics() *Metrics {
+	db.mu.RLock()
+	reclaimSize := db.reclaimSize
+	db.mu.RUnlock()
+
+	m := db.metrics
+	return &Metrics{
+		PutLatency:          m.putLatency.Snapshot(),
+		GetLatency:          m.getLatency.Snapshot(),
+		DeleteLatency:       m.deleteLatency.Snapshot(),
+		BytesWritten:        m.bytesWritten.Value(),
+		SyncCount:           m.syncCount.Value(),
+		SyncLatency:         m.syncLatency.Snapshot(),
+		FileRotations:       m.fileRotations.Value(),
+		MergeLatency:        m.mergeLatency.Snapshot(),
+		MergeReclaimedBytes: m.mergeReclaimedBytes.Value(),
+		IteratorCount:       m.iteratorCount.Value(),
+		KeyNum:              uint(db.index.Size()),
+		ReclaimableSize:     reclaimSize,
+		ValueCache:          db.valueCache.Stats(),
+	}
+}
+
+// WritePrometheus 将指标以 Prometheus 文本格式输出
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	histograms := []struct {
+		name, help string
+		snap       metrics.HistogramSnapshot
+	}{
+		{"bitcask_put_duration_seconds", "Latency of Put operations.", m.PutLatency},
+		{"bitcask_get_duration_seconds", "Latency of Get operations.", m.GetLatency},
+		{"bitcask_delete_duration_seconds", "Latency of Delete operations.", m.DeleteLatency},
+		{"bitcask_sync_duration_seconds", "Latency of data file fsync.", m.SyncLatency},
+		{"bitcask_merge_duration_seconds", "Duration of completed merges.", m.MergeLatency},
+	}
+	for _, h := range histograms {
+		if err := metrics.WriteHistogram(w, h.name, h.help, h.snap); err != nil {
+			return err
+		}
+	}
+
+	counters := []struct {
+		name, help string
+		value      uint64
+	}{
+		{"bitcask_written_bytes_total", "Bytes appended to data files.", m.BytesWritten},
+		{"bitcask_sync_total", "Number of data file fsync calls.", m.SyncCount},
+		{"bitcask_file_rotations_total", "Number of active data file rotations.", m.FileRotations},
+		{"bitcask_merge_reclaimed_bytes_total", "Bytes reclaimed by merge.", m.MergeReclaimedBytes},
+		{"bitcask_iterators_total", "Number of iterators created.", m.IteratorCount},
+		{"bitcask_value_cache_hits_total", "Number of value cache hits.", m.ValueCache.Hits},
+		{"bitcask_value_cache_misses_total", "Number of value cache misses.", m.ValueCache.Misses},
+		{"bitcask_value_cache_evictions_total", "Number of values evicted from the value cache.", m.ValueCache.Evictions},
+	}
+	for _, c := range counters {
+		if err := metrics.WriteCounter(w, c.name, c.help, c.value); err != nil {
+			return err
+		}
+	}
+
+	if err := metrics.WriteGauge(w, "bitcask_keys", "Number of keys in the index.", float64(m.KeyNum)); err != nil {
+		return err
+	}
+	if err := metrics.WriteGauge(w, "bitcask_value_cache_bytes", "Bytes used by the value cache.", float64(m.ValueCache.Bytes)); err != nil {
+		return err
+	}
+	return metrics.WriteGauge(w, "bitcask_reclaimable_bytes", "Bytes that can be reclaimed by merge.", float64(m.ReclaimableSize))
+}
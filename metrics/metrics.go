@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets 默认的耗时分桶上界，单位为秒，从 10 微秒到 10 秒
+var DefaultLatencyBuckets = []float64{
+	0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10,
+}
+
+// Counter 单调递增的计数器，并发安全
+type Counter struct {
+	value uint64
+}
+
+// Inc 计数器加 1
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+// Add 计数器加 n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Value 获取计数器当前的值
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Histogram 耗时直方图，并发安全
+type Histogram struct {
+	bounds []float64 // 每个桶的上界，单位为秒，从小到大排列
+	counts []uint64  // 每个桶中的数量（不累加），最后一个是 +Inf 桶
+	count  uint64    // 总的观测次数
+	sum    uint64    // 所有观测值之和，单位为纳秒
+}
+
+// NewHistogram 根据给定的桶上界初始化直方图
+func NewHistogram(bounds []float64) *Histogram {
+	b := make([]float64, len(bounds))
+	copy(b, bounds)
+	sort.Float64s(b)
+	return &Histogram{
+		bounds: b,
+		counts: make([]uint64, len(b)+1),
+	}
+}
+
+// Observe 记录一次耗时
+func (h *Histogram) Observe(d time.Duration) {
+	seconds := d.Seconds()
+	idx := sort.SearchFloat64s(h.bounds, seconds)
+	atomic.AddUint64(&h.counts[idx], 1)
+	atomic.AddUint64(&h.count, 1)
+	atomic.AddUint64(&h.sum, uint64(d))
+}
+
+// ObserveSince 记录从 start 到现在的耗时
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start))
+}
+
+// Snapshot 获取直方图当前数据的快照
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	snap := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.bounds)),
+	}
+	// Prometheus 中每个桶的数量是累加的
+	var cumulative uint64
+	for i := range h.bounds {
+		cumulative += atomic.LoadUint64(&h.counts[i])
+		snap.Counts[i] = cumulative
+	}
+	snap.Count = atomic.LoadUint64(&h.count)
+	snap.Sum = time.Duration(atomic.LoadUint64(&h.sum)).Seconds()
+	return snap
+}
+
+// HistogramSnapshot 直方图的快照
+type HistogramSnapshot struct {
+	Bounds []float64 // 每个桶的上界，单位为秒
+	Counts []uint64  // 小于等于对应上界的观测次数（累加值）
+	Count  uint64    // 总的观测次数
+	Sum    float64   // 所有观测值之和，单位为秒
+}
+
+// WriteCounter 以 Prometheus 文本格式输出计数器
+func WriteCounter(w io.Writer, name, help string, value uint64) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	return err
+}
+
+// WriteGauge 以 Prometheus 文本格式输出瞬时值
+func WriteGauge(w io.Writer, name, help string, value float64) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", name, help, name, name, value)
+	return err
+}
+
+// WriteHistogram 以 Prometheus 文本格式输出直方图
+func WriteHistogram(w io.Writer, name, help string, snap HistogramSnapshot) error {
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name); err != nil {
+		return err
+	}
+	for i, bound := range snap.Bounds {
+		if _, err := fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, bound, snap.Counts[i]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n",
+		name, snap.Count, name, snap.Sum, name, snap.Count)
+	return err
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	c := &Counter{}
+	c.Inc()
+	c.Add(10)
+	assert.Equal(t, uint64(11), c.Value())
+}
+
+func TestHistogram_Snapshot(t *testing.T) {
+	h := NewHistogram([]float64{0.001, 0.01, 0.1})
+	h.Observe(500 * time.Microsecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(5 * time.Millisecond)
+	h.Observe(time.Second)
+
+	snap := h.Snapshot()
+	assert.Equal(t, []uint64{1, 3, 3}, snap.Counts)
+	assert.Equal(t, uint64(4), snap.Count)
+	assert.InDelta(t, 1.0105, snap.Sum, 1e-9)
+}
+
+func TestWriteHistogram(t *testing.T) {
+	h := NewHistogram([]float64{0.001, 0.01})
+	h.Observe(5 * time.Millisecond)
+
+	var buf bytes.Buffer
+	err := WriteHistogram(&buf, "test_duration_seconds", "test histogram", h.Snapshot())
+	assert.Nil(t, err)
+	expected := `# HELP test_duration_seconds test histogram
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.001"} 0
+test_duration_seconds_bucket{le="0.01"} 1
+test_duration_seconds_bucket{le="+Inf"} 1
+test_duration_seconds_sum 0.005
+test_duration_seconds_count 1
+`
+	assert.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = WriteCounter(&buf, "test_total", "test counter", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "# HELP test_total test counter\n# TYPE test_total counter\ntest_total 3\n", buf.String())
+}
@@ -1,6 +1,11 @@
 package bitcask_go
 
-import "os"
+import (
+	"myRosedb/data"
+	"myRosedb/fio"
+	"os"
+	"time"
+)
 
 type Options struct {
 	// 数据库数据目录
@@ -22,10 +27,86 @@ type Options struct {
 	// 启动时是否使用 MMap 进行加载
 	MMapAtStartup bool
 
+	// 数据文件读写使用的 IO 类型，运行时可以通过 DB.SetIOType 修改
+	IOType IOType
+
+	// 哪些数据文件使用直接 IO（O_DIRECT），绕过操作系统的页缓存，优先于 IOType，只支持 linux
+	DirectIO DirectIOMode
+
 	// 数据文件合并的阈值，无效文件在总数量当中的比例
 	DataFileMergeRatio float32
+
+	// merge 时只重写无效数据比例不低于该值的数据文件，0 表示重写所有的数据文件
+	MergeFileGarbageRatio float32
+
+	// 每次 merge 最多重写的数据文件数量，优先选择无效数据比例最高的文件，0 表示不限制
+	MergeMaxFiles int
+
+	// merge 读取和写入数据文件的速度上限（字节/秒），避免 merge 占满磁盘带宽，0 表示不限速
+	MergeRateLimit int64
+
+	// merge 时每个 key 保留的最近版本数量（包括当前版本和删除标记），不能超过 HistoryVersions，0 表示只保留当前版本
+	MergeKeepVersions int
+
+	// 内存中为每个 key 记录的最近版本数量（包括当前版本），用于 DB.History 查询历史版本，0 表示不记录
+	HistoryVersions int
+
+	// 数据目录所在磁盘最少需要保留的可用空间，低于该值时拒绝写入，0 表示不检查
+	MinFreeDiskSize uint64
+
+	// 日志输出，与 *slog.Logger 兼容，为空时不输出任何日志
+	Logger Logger
+
+	// 静态加密配置，为空时不加密
+	Encryption *EncryptionOptions
+
+	// key 的最大长度（字节），0 表示只受存储格式的限制
+	MaxKeySize uint32
+
+	// value 的最大长度（字节），0 表示只受存储格式的限制（key 和 value 的长度之和不能超过 4GB）
+	// 超过 DataFileSize 的记录会单独写入一个数据文件
+	MaxValueSize uint32
+
+	// 额外存放数据文件的目录，新的数据文件按照 DataDirPolicy 分布在 DirPath 和这些目录中
+	// hint 文件、事务序列号文件等其他文件只存放在 DirPath 中，merge 之后的数据文件也会放到 DirPath 中
+	// 每个目录只能由一个数据库实例使用，已有的数据文件所在的目录不能从中移除
+	DataDirs []string
+
+	// 新的数据文件选择目录的策略
+	DataDirPolicy DataDirPolicy
+
+	// 冷存储目录，旧的数据文件按照 TieringPolicy 在后台迁移到这个目录，为空表示不开启分层存储
+	// 这个目录只能由一个数据库实例使用
+	ColdDirPath string
+
+	// 冷热分层的策略，开启分层存储时不能为空
+	TieringPolicy TieringPolicy
+
+	// 后台检查冷数据文件的间隔，小于等于 0 时只能通过 DB.MoveColdFiles 手动迁移
+	TieringInterval time.Duration
+
+	// value 缓存可以使用的内存大小（字节），按照 LRU 淘汰，0 表示不开启缓存
+	ValueCacheSize int64
+
+	// 写入数据时使用的校验类型，读取时根据每条记录中保存的类型进行校验，所以可以随时修改
+	ChecksumType ChecksumType
+
+	// 打开数据库时自动创建的二级索引，索引名称 -> 提取索引 key 的函数
+	// 二级索引不写入数据文件，每次打开时都会遍历所有的 key 重新回填
+	SecondaryIndexes map[string]IndexExtractor
 }
 
+// EncryptionOptions 静态加密配置项
+// 数据文件、hint 文件以及事务序列号文件中每条记录的 key/value 都会使用 AES-GCM 加密
+type EncryptionOptions struct {
+	// 提供加密使用的密钥，密钥 id 会写入每条记录的头部
+	// 轮换密钥后，旧的数据仍然使用旧密钥解密，merge 时会使用最新的密钥重新加密
+	KeyProvider KeyProvider
+}
+
+// KeyProvider 加密密钥提供者，可以使用 data.NewStaticKeyProvider 创建
+type KeyProvider = data.KeyProvider
+
 // IteratorOptions 索引迭代器配置项
 type IteratorOptions struct {
 	// 遍历前缀为指定的 Key，默认为空
@@ -40,6 +121,9 @@ type WriteBatchOptions struct {
 	// 一个批次当中最大的数据量
 	MaxBatchNum uint
 
+	// 一个批次当中 key 和 value 的最大总长度（字节），超过时拒绝写入，0 表示不限制
+	MaxBatchBytes int64
+
 	// 在提交数据的时候是否进行 Sync 持久化
 	SyncWrites bool
 }
@@ -57,6 +141,46 @@ const (
 	BPlusTree
 )
 
+type IOType = fio.FileIOType
+
+const (
+	// StandardIO 标准文件 IO
+	StandardIO = fio.StandardFIO
+
+	// MemoryMapIO 可读写的内存文件映射，活跃文件会预先映射 DataFileSize 大小的空间
+	MemoryMapIO = fio.MemoryMapRW
+)
+
+type DirectIOMode = uint8
+
+const (
+	// DirectIONone 不使用直接 IO
+	DirectIONone DirectIOMode = 0
+
+	// DirectIOReads 旧的数据文件使用直接 IO 读取
+	DirectIOReads DirectIOMode = 1 << 0
+
+	// DirectIOWrites 活跃文件使用直接 IO 写入和读取
+	// 每次写入都会把最后一个不完整的块用 0 填充后整块写入磁盘，小数据写入的开销比标准文件 IO 更大
+	DirectIOWrites DirectIOMode = 1 << 1
+
+	// DirectIOAll 所有数据文件都使用直接 IO
+	DirectIOAll = DirectIOReads | DirectIOWrites
+)
+
+type ChecksumType = data.ChecksumType
+
+const (
+	// ChecksumCRC32 crc32 IEEE 校验
+	ChecksumCRC32 = data.ChecksumCRC32
+
+	// ChecksumCRC32C crc32 Castagnoli 校验
+	ChecksumCRC32C = data.ChecksumCRC32C
+
+	// ChecksumXXHash64 xxHash64 校验
+	ChecksumXXHash64 = data.ChecksumXXHash64
+)
+
 var DefaultOptions = Options{
 	DirPath:            os.TempDir(),
 	DataFileSize:       256 * 1024 * 1024, // 256MB
@@ -64,7 +188,16 @@ var DefaultOptions = Options{
 	BytesPerSync:       0, // 默认不开启
 	IndexType:          BTree,
 	MMapAtStartup:      true,
+	IOType:             StandardIO,
+	DirectIO:           DirectIONone,
 	DataFileMergeRatio: 0.5,
+	MinFreeDiskSize:    0, // 默认不开启
+	ChecksumType:       ChecksumCRC32,
+	ValueCacheSize:     0, // 默认不开启
+	MaxKeySize:         0, // 默认不限制，已有数据中可能存在较长的 key
+	MaxValueSize:       0,
+	TieringInterval:    time.Minute,
+	DataDirPolicy:      DataDirRoundRobin,
 }
 
 var DefaultIteratorOptions = IteratorOptions{
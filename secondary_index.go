@@ -0,0 +1,164 @@
+package bitcask_go
+
+import (
+	"myRosedb/index"
+)
+
+// IndexExtractor 从一条数据中提取二级索引的 key，一条数据可以对应多个索引 key，返回空表示不加入索引
+// 同样的 key 和 value 必须返回同样的结果，不能修改传入的 key 和 value
+// 写入时在持有数据库写锁的情况下调用，不能再调用数据库的任何方法，否则会死锁
+type IndexExtractor func(key, value []byte) [][]byte
+
+// IndexScanOptions 二级索引的查询范围
+type IndexScanOptions struct {
+	Start []byte // 索引 key 的起点，包含在内
+	End   []byte // 索引 key 的终点，不包含在内，为空表示没有终点
+	Limit int    // 最多返回的数量，小于等于 0 表示不限制
+}
+
+// IndexPrefix 查询所有以 prefix 开头的索引 key
+func IndexPrefix(prefix []byte) IndexScanOptions {
+	return IndexScanOptions{Start: prefix, End: prefixEnd(prefix)}
+}
+
+// IndexEntry 二级索引的查询结果
+type IndexEntry struct {
+	IndexKey []byte // 匹配的索引 key
+	Key      []byte // 主键
+	Value    []byte // 主键当前的 value
+}
+
+type secondaryIndex struct {
+	extractor IndexExtractor
+	entries   *index.SecondaryIndex
+}
+
+// CreateIndex 创建一个二级索引，并用已有的数据回填，之后的写入会在更新内存索引时同步更新二级索引
+// 二级索引只保存在内存中，关闭之后丢失，需要一直使用的索引可以配置在 Options.SecondaryIndexes 中，每次打开时重新创建
+func (db *DB) CreateIndex(name string, extractor IndexExtractor) error {
+	if name == "" || extractor == nil {
+		return ErrInvalidIndex
+	}
+
+	// 写入在持有 db.mu 的写锁时更新二级索引，注册和回填期间持有读锁，不会有并发的写入
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	si := &secondaryIndex{extractor: extractor, entries: index.NewSecondaryIndex()}
+	db.indexMu.Lock()
+	if _, ok := db.secondaryIndexes[name]; ok {
+		db.indexMu.Unlock()
+		return ErrIndexExists
+	}
+	db.secondaryIndexes[name] = si
+	db.indexMu.Unlock()
+
+	if err := db.backfillIndex(si); err != nil {
+		db.indexMu.Lock()
+		delete(db.secondaryIndexes, name)
+		db.indexMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// DropIndex 删除一个二级索引
+func (db *DB) DropIndex(name string) error {
+	db.indexMu.Lock()
+	defer db.indexMu.Unlock()
+	if _, ok := db.secondaryIndexes[name]; !ok {
+		return ErrIndexNotFound
+	}
+	delete(db.secondaryIndexes, name)
+	return nil
+}
+
+// IndexScan 按照 (索引 key, 主键) 的顺序查询二级索引中在范围内的数据
+// 一个主键有多个索引 key 在范围内时会返回多次
+func (db *DB) IndexScan(name string, opts IndexScanOptions) ([]*IndexEntry, error) {
+	si := db.getSecondaryIndex(name)
+	if si == nil {
+		return nil, ErrIndexNotFound
+	}
+
+	// 二级索引和内存索引在同一把写锁中更新，持有读锁时两者一致，边遍历边读取 value，达到 Limit 之后停止
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var entries []*IndexEntry
+	var err error
+	si.entries.Ascend(opts.Start, opts.End, func(indexKey, key []byte) bool {
+		pos := db.index.Get(key)
+		if pos == nil {
+			return true
+		}
+		var value []byte
+		if value, err = db.getValueByPosition(pos); err != nil {
+			return false
+		}
+		entries = append(entries, &IndexEntry{
+			IndexKey: append([]byte(nil), indexKey...),
+			Key:      append([]byte(nil), key...),
+			Value:    value,
+		})
+		return opts.Limit <= 0 || len(entries) < opts.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// 用已有的数据回填二级索引
+// 在访问此方法前必须持有 db.mu
+func (db *DB) backfillIndex(si *secondaryIndex) error {
+	iterator := db.index.Iterator(false)
+	defer iterator.Close()
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		value, err := db.getValueByPosition(iterator.Value())
+		if err != nil {
+			return err
+		}
+		si.entries.Set(iterator.Key(), si.extractor(iterator.Key(), value))
+	}
+	return nil
+}
+
+func (db *DB) getSecondaryIndex(name string) *secondaryIndex {
+	db.indexMu.RLock()
+	defer db.indexMu.RUnlock()
+	return db.secondaryIndexes[name]
+}
+
+func (db *DB) hasSecondaryIndexes() bool {
+	db.indexMu.RLock()
+	defer db.indexMu.RUnlock()
+	return len(db.secondaryIndexes) > 0
+}
+
+// 写入 key 之后更新所有的二级索引，需要在持有 db.mu 写锁时和内存索引一起更新
+func (db *DB) indexWrite(key, value []byte) {
+	db.indexMu.RLock()
+	defer db.indexMu.RUnlock()
+	for _, si := range db.secondaryIndexes {
+		si.entries.Set(key, si.extractor(key, value))
+	}
+}
+
+// 删除 key 之后从所有的二级索引中删除，需要在持有 db.mu 写锁时和内存索引一起更新
+func (db *DB) indexDelete(key []byte) {
+	db.indexMu.RLock()
+	defer db.indexMu.RUnlock()
+	for _, si := range db.secondaryIndexes {
+		si.entries.Remove(key)
+	}
+}
+
+// 清空所有二级索引中的数据，索引本身保留
+func (db *DB) resetSecondaryIndexes() {
+	db.indexMu.RLock()
+	defer db.indexMu.RUnlock()
+	for _, si := range db.secondaryIndexes {
+		si.entries.Reset()
+	}
+}
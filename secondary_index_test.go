@@ -0,0 +1,120 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"myRosedb/data"
+	"os"
+	"testing"
+)
+
+// value 的格式为 城市:年龄，按照城市建立索引
+func cityExtractor(key, value []byte) [][]byte {
+	city, _, ok := bytes.Cut(value, []byte(":"))
+	if !ok {
+		return nil
+	}
+	return [][]byte{city}
+}
+
+func indexScanKeys(t *testing.T, db *DB, opts IndexScanOptions) []string {
+	entries, err := db.IndexScan("city", opts)
+	assert.Nil(t, err)
+	var keys []string
+	for _, entry := range entries {
+		keys = append(keys, string(entry.Key))
+	}
+	return keys
+}
+
+func TestDB_SecondaryIndex(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-secondary-index")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer func() { destroyDB(db) }()
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put([]byte("u1"), []byte("beijing:20")))
+	assert.Nil(t, db.Put([]byte("u2"), []byte("shanghai:30")))
+	assert.Nil(t, db.Put([]byte("u3"), []byte("no-city")))
+
+	// 创建时用已有的数据回填
+	assert.Nil(t, db.CreateIndex("city", cityExtractor))
+	assert.Equal(t, ErrIndexExists, db.CreateIndex("city", cityExtractor))
+	assert.Equal(t, ErrInvalidIndex, db.CreateIndex("", cityExtractor))
+	entries, err := db.IndexScan("city", IndexPrefix([]byte("beijing")))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, []byte("beijing"), entries[0].IndexKey)
+	assert.Equal(t, []byte("u1"), entries[0].Key)
+	assert.Equal(t, []byte("beijing:20"), entries[0].Value)
+
+	// 写入和删除同步更新索引
+	assert.Nil(t, db.Put([]byte("u4"), []byte("beijing:40")))
+	assert.Nil(t, db.Put([]byte("u2"), []byte("beijing:31")))
+	assert.Nil(t, db.Delete([]byte("u1")))
+	assert.Equal(t, []string{"u2", "u4"}, indexScanKeys(t, db, IndexPrefix([]byte("beijing"))))
+	assert.Nil(t, indexScanKeys(t, db, IndexPrefix([]byte("shanghai"))))
+
+	// 事务中的写入在提交时一起更新索引
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put([]byte("u5"), []byte("shanghai:50")))
+	assert.Nil(t, wb.Delete([]byte("u4")))
+	assert.Nil(t, indexScanKeys(t, db, IndexPrefix([]byte("shanghai"))))
+	assert.Nil(t, wb.Commit())
+	assert.Equal(t, []string{"u5"}, indexScanKeys(t, db, IndexPrefix([]byte("shanghai"))))
+	assert.Equal(t, []string{"u2"}, indexScanKeys(t, db, IndexPrefix([]byte("beijing"))))
+
+	// 按照范围查询
+	assert.Nil(t, db.Put([]byte("u6"), []byte("guangzhou:60")))
+	assert.Equal(t, []string{"u2", "u6"}, indexScanKeys(t, db, IndexScanOptions{Start: []byte("b"), End: []byte("s")}))
+	assert.Equal(t, []string{"u2", "u6", "u5"}, indexScanKeys(t, db, IndexScanOptions{}))
+	assert.Equal(t, []string{"u2"}, indexScanKeys(t, db, IndexScanOptions{Limit: 1}))
+
+	// 范围删除
+	assert.Nil(t, db.DeletePrefix([]byte("u5")))
+	assert.Nil(t, indexScanKeys(t, db, IndexPrefix([]byte("shanghai"))))
+
+	// 重新打开之后需要重新创建，创建时回填
+	assert.Nil(t, db.Close())
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	_, err = db.IndexScan("city", IndexScanOptions{})
+	assert.Equal(t, ErrIndexNotFound, err)
+	assert.Nil(t, db.CreateIndex("city", cityExtractor))
+	assert.Equal(t, []string{"u2", "u6"}, indexScanKeys(t, db, IndexScanOptions{}))
+
+	// 配置的二级索引在打开时自动创建
+	assert.Nil(t, db.Close())
+	opts.SecondaryIndexes = map[string]IndexExtractor{"city": cityExtractor}
+	db, err = Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"u2", "u6"}, indexScanKeys(t, db, IndexScanOptions{}))
+
+	assert.Nil(t, db.DropAll())
+	assert.Nil(t, indexScanKeys(t, db, IndexScanOptions{}))
+
+	assert.Nil(t, db.DropIndex("city"))
+	assert.Equal(t, ErrIndexNotFound, db.DropIndex("city"))
+}
+
+func TestDB_IndexScanLimit(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-secondary-index-limit")
+	opts.DirPath = dir
+	db, err := Open(opts)
+	defer func() { destroyDB(db) }()
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put([]byte("u1"), []byte("beijing:20")))
+	assert.Nil(t, db.Put([]byte("u2"), []byte("beijing:30")))
+	assert.Nil(t, db.Put([]byte("u3"), []byte("beijing:40")))
+	assert.Nil(t, db.CreateIndex("city", cityExtractor))
+
+	// u3 指向不存在的数据文件，读取时会出错，达到 Limit 之后不再读取后面的数据
+	db.index.Put([]byte("u3"), &data.LogRecordPos{Fid: 99})
+	assert.Equal(t, []string{"u1", "u2"}, indexScanKeys(t, db, IndexScanOptions{Limit: 2}))
+	_, err = db.IndexScan("city", IndexScanOptions{})
+	assert.Equal(t, ErrDataFileNotFound, err)
+}
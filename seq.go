@@ -0,0 +1,81 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"sync/atomic"
+	"time"
+)
+
+// LatestSeq 获取最近分配的序列号，每次写入都会分配一个递增的序列号，同一个事务中的记录序列号相同
+// 序列号在写入数据文件之前分配，返回的序列号对应的写入可能还没有完成
+// DropAll 之后序列号继续递增，不会重复使用
+func (db *DB) LatestSeq() uint64 {
+	return atomic.LoadUint64(&db.seqNo)
+}
+
+// GetWithSeq 根据 key 读取数据，同时返回写入这个 value 时分配的序列号
+// 旧版本写入的数据中没有保存序列号，返回 0
+func (db *DB) GetWithSeq(key []byte) ([]byte, uint64, error) {
+	defer db.metrics.getLatency.ObserveSince(time.Now())
+	if len(key) == 0 {
+		return nil, 0, ErrKeyIsEmpty
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	logRecordPos := db.index.Get(key)
+	if logRecordPos == nil {
+		return nil, 0, ErrKeyNotFound
+	}
+	logRecord, err := db.readLogRecord(logRecordPos)
+	if err != nil {
+		return nil, 0, err
+	}
+	return logRecord.Value, db.recordSeq(logRecord), nil
+}
+
+// 记录对外的序列号，LargeBatch 中的记录返回提交时分配的序列号，旧版本写入的数据中没有保存序列号，返回 0
+// 在访问此方法前必须持有读锁
+func (db *DB) recordSeq(logRecord *data.LogRecord) uint64 {
+	if logRecord.Version == nil {
+		return 0
+	}
+	if _, txnSeqNo := parseLogRecordKey(logRecord.Key); txnSeqNo != nonTransactionSeqNo {
+		if seqNo, ok := db.txnSeqs[txnSeqNo]; ok {
+			return seqNo
+		}
+	}
+	return logRecord.Version.Seq
+}
+
+// 根据位置读取完整的 LogRecord，不经过 value 缓存
+// 在访问此方法前必须持有读锁
+func (db *DB) readLogRecord(pos *data.LogRecordPos) (*data.LogRecord, error) {
+	var dataFile *data.DataFile
+	if db.activeFile != nil && db.activeFile.FileID == pos.Fid {
+		dataFile = db.activeFile
+	} else {
+		dataFile = db.olderFiles[pos.Fid]
+	}
+	if dataFile == nil {
+		return nil, ErrDataFileNotFound
+	}
+
+	db.recordFileRead(pos.Fid)
+	logRecord, _, err := dataFile.ReadLogRecord(pos.Offset)
+	return logRecord, err
+}
+
+// 为写入的记录生成版本信息，seqNo 为分配的序列号
+func (db *DB) newRecordVersion(seqNo uint64) *data.RecordVersion {
+	return &data.RecordVersion{Seq: seqNo, Timestamp: time.Now().UnixNano()}
+}
+
+// 为还没有版本信息的记录分配新的序列号，事务完成的标识不是用户数据，不需要版本信息
+// 在访问此方法前必须持有互斥锁
+func (db *DB) stampVersion(logRecord *data.LogRecord) {
+	if logRecord.Version != nil || logRecord.Type == data.LogRecordTxnFinished {
+		return
+	}
+	logRecord.Version = db.newRecordVersion(atomic.AddUint64(&db.seqNo, 1))
+}
@@ -0,0 +1,141 @@
+package bitcask_go
+
+import (
+	"github.com/stretchr/testify/assert"
+	"myRosedb/utils"
+	"os"
+	"testing"
+)
+
+func TestDB_Seq(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-seq")
+	opts.DirPath = dir
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	defer destroyDB(db)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), db.LatestSeq())
+
+	seq1, err := db.PutWithSeq([]byte("name"), []byte("v1"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), seq1)
+	seq2, err := db.PutWithSeq([]byte("name"), []byte("v2"))
+	assert.Nil(t, err)
+	assert.Greater(t, seq2, seq1)
+
+	val, seq, err := db.GetWithSeq([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), val)
+	assert.Equal(t, seq2, seq)
+	_, _, err = db.GetWithSeq([]byte("unknown"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// 事务中的数据共用一个序列号
+	wb := db.NewWriteBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, wb.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, wb.Put([]byte("b"), []byte("2")))
+	seq3, err := wb.CommitWithSeq()
+	assert.Nil(t, err)
+	assert.Greater(t, seq3, seq2)
+	_, seqA, _ := db.GetWithSeq([]byte("a"))
+	_, seqB, _ := db.GetWithSeq([]byte("b"))
+	assert.Equal(t, seq3, seqA)
+	assert.Equal(t, seq3, seqB)
+	seq, err = wb.CommitWithSeq()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), seq)
+
+	// LargeBatch 的序列号在提交时分配，比创建之后其他写入的序列号更大
+	lb := db.NewLargeBatch(DefaultWriteBatchOptions)
+	assert.Nil(t, lb.Put([]byte("c"), []byte("3")))
+	seqD, err := db.PutWithSeq([]byte("d"), []byte("4"))
+	assert.Nil(t, err)
+	assert.Greater(t, seqD, seq3)
+	seq4, err := lb.CommitWithSeq()
+	assert.Nil(t, err)
+	assert.Greater(t, seq4, seqD)
+	_, seq, err = db.GetWithSeq([]byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, seq4, seq)
+
+	// 不存在的 key 不需要删除
+	seq, err = db.DeleteWithSeq([]byte("unknown"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), seq)
+	seq5, err := db.DeleteWithSeq([]byte("a"))
+	assert.Nil(t, err)
+	assert.Greater(t, seq5, seq4)
+	assert.Equal(t, seq5, db.LatestSeq())
+
+	// 重启之后序列号继续递增
+	assert.Nil(t, db.Close())
+	db2, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, seq5, db2.LatestSeq())
+	_, seq, err = db2.GetWithSeq([]byte("name"))
+	assert.Nil(t, err)
+	assert.Equal(t, seq2, seq)
+	_, seq, err = db2.GetWithSeq([]byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, seq4, seq)
+	seq6, err := db2.PutWithSeq([]byte("name"), []byte("v3"))
+	assert.Nil(t, err)
+	assert.Greater(t, seq6, seq5)
+
+	// merge 之后保留每条记录的序列号
+	assert.Nil(t, db2.Merge())
+	assert.Nil(t, db2.Close())
+	db3, err := Open(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, seq6, db3.LatestSeq())
+	_, seq, err = db3.GetWithSeq([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, seq3, seq)
+	_, seq, err = db3.GetWithSeq([]byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, seq4, seq)
+	assert.Nil(t, db3.Close())
+}
+
+// merge 丢弃了序列号最大的记录之后，重启时序列号也不能回退
+func TestDB_SeqAfterMerge(t *testing.T) {
+	opts := DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-seq-merge")
+	opts.DirPath = dir
+	opts.DataFileMergeRatio = 0
+	db, err := Open(opts)
+	assert.Nil(t, err)
+
+	assert.Nil(t, db.Put([]byte("a"), []byte("1")))
+	assert.Nil(t, db.Put([]byte("b"), []byte("2")))
+	seq, err := db.DeleteWithSeq([]byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), seq)
+	assert.Nil(t, db.Merge())
+
+	// 模拟 merge 之后没有关闭就崩溃，拷贝数据目录和 merge 目录之后打开
+	crashDir, _ := os.MkdirTemp("", "bitcask-go-seq-crash")
+	defer func() {
+		_ = os.RemoveAll(crashDir)
+		_ = os.RemoveAll(crashDir + mergeDirName)
+	}()
+	assert.Nil(t, utils.CopyDir(dir, crashDir, []string{fileLockName}))
+	assert.Nil(t, utils.CopyDir(dir+mergeDirName, crashDir+mergeDirName, nil))
+
+	assert.Nil(t, db.Close())
+	for _, path := range []string{dir, crashDir} {
+		opts.DirPath = path
+		db2, err := Open(opts)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), db2.LatestSeq())
+		seq, err = db2.PutWithSeq([]byte("c"), []byte("3"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(4), seq)
+		if path == dir {
+			destroyDB(db2)
+		} else {
+			assert.Nil(t, db2.Close())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"io"
+	"myRosedb/data"
+	"time"
+)
+
+// PutReader 从 r 中读取 size 个字节作为 key 对应的 value，分块写入活跃文件，value 不会整体读入内存
+// 写入期间会一直持有写锁，r 读取失败时已经写入的部分会被丢弃
+// 开启加密时 value 需要整体加密，会先读入内存再写入
+func (db *DB) PutReader(key []byte, r io.Reader, size int64) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	if size < 0 {
+		return data.ErrInvalidStreamSize
+	}
+	if err := db.checkKeyValueSize(key, size); err != nil {
+		return err
+	}
+	if db.cipher != nil {
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return err
+		}
+		return db.Put(key, value)
+	}
+	defer db.metrics.putLatency.ObserveSince(time.Now())
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	pos, err := db.appendStream(&data.LogRecord{Key: logRecordKeyWithSeq(key, nonTransactionSeqNo)}, r, size)
+	if err != nil {
+		return err
+	}
+
+	// 更新内存索引
+	oldPos := db.index.Put(key, pos)
+	if oldPos != nil {
+		db.reclaimSize += int64(oldPos.Size)
+		db.valueCache.Remove(valueCacheKey(oldPos))
+	}
+	db.addFileUsage(pos, oldPos)
+	db.versions.Add(key, pos)
+
+	// value 没有整体读入内存，有二级索引时从数据文件中读取
+	if db.hasSecondaryIndexes() {
+		value, err := db.getValueByPosition(pos)
+		if err != nil {
+			return err
+		}
+		db.indexWrite(key, value)
+	}
+	return nil
+}
+
+// 流式追加写入一条记录，logRecord 中只有 key 和版本信息会被使用
+// 在访问此方法前必须持有互斥锁
+func (db *DB) appendStream(logRecord *data.LogRecord, r io.Reader, size int64) (*data.LogRecordPos, error) {
+	if db.activeFile == nil {
+		if err := db.setActiveDataFile(); err != nil {
+			return nil, err
+		}
+	}
+	db.stampVersion(logRecord)
+	if err := db.prepareAppend(data.StreamedRecordSize(logRecord, size)); err != nil {
+		return nil, err
+	}
+
+	writeOff := db.activeFile.WriteOff
+	n, err := db.activeFile.WriteStream(logRecord, r, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.finishAppend(n); err != nil {
+		return nil, err
+	}
+	return &data.LogRecordPos{Fid: db.activeFile.FileID, Offset: writeOff, Size: uint32(n)}, nil
+}
+
+// GetReader 返回 key 对应的 value 的 io.ReadSeeker，按需从数据文件中读取，value 不会整体读入内存
+// 读取的同时增量计算校验值，读到末尾时进行校验，校验失败返回 data.ErrInvalidCRC
+// 返回的 reader 在数据库关闭或者数据文件被迁移到冷存储目录之前有效，不能并发使用
+// 加密的 value 需要整体解密，会先读入内存
+func (db *DB) GetReader(key []byte) (io.ReadSeeker, error) {
+	if len(key) == 0 {
+		return nil, ErrKeyIsEmpty
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	logRecordPos := db.index.Get(key)
+	if logRecordPos == nil {
+		return nil, ErrKeyNotFound
+	}
+	var dataFile *data.DataFile
+	if db.activeFile != nil && db.activeFile.FileID == logRecordPos.Fid {
+		dataFile = db.activeFile
+	} else {
+		dataFile = db.olderFiles[logRecordPos.Fid]
+	}
+	if dataFile == nil {
+		return nil, ErrDataFileNotFound
+	}
+
+	db.recordFileRead(logRecordPos.Fid)
+	reader, err := dataFile.NewValueReader(logRecordPos.Offset)
+	if err == data.ErrValueEncrypted {
+		value, err := db.getValueByPosition(logRecordPos)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(value), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
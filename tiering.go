@@ -0,0 +1,215 @@
+package bitcask_go
+
+import (
+	"myRosedb/data"
+	"myRosedb/metrics"
+	"myRosedb/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 迁移到冷存储目录时先写入的临时文件的后缀
+const tieringTmpSuffix = ".tmp"
+
+// TieringPolicy 冷热分层的策略，决定哪些旧的数据文件需要迁移到 ColdDirPath
+type TieringPolicy interface {
+	// IsCold 返回数据文件是否已经变冷，需要迁移到冷存储目录
+	IsCold(stat DataFileStat) bool
+}
+
+// DataFileStat 数据文件的统计信息，也用于判断数据文件的冷热
+type DataFileStat struct {
+	Fid       uint32    // 文件 id
+	DirPath   string    // 文件所在的目录
+	Size      int64     // 文件大小
+	ModTime   time.Time // 最后一次写入的时间，即转换为旧的数据文件的时间
+	Reads     uint64    // 上一次检查冷热之后从磁盘读取的次数，命中 value 缓存的不算
+	LiveBytes int64     // 有效数据的字节数
+	DeadBytes int64     // 被覆盖或者删除的无效数据的字节数
+}
+
+// FileAgePolicy 转换为旧的数据文件超过 MinAge 之后迁移
+type FileAgePolicy struct {
+	MinAge time.Duration
+}
+
+func (p FileAgePolicy) IsCold(stat DataFileStat) bool {
+	return time.Since(stat.ModTime) >= p.MinAge
+}
+
+// AccessFrequencyPolicy 转换为旧的数据文件超过 MinAge，并且两次检查之间的读取次数不超过 MaxReads 时迁移
+type AccessFrequencyPolicy struct {
+	MinAge   time.Duration
+	MaxReads uint64
+}
+
+func (p AccessFrequencyPolicy) IsCold(stat DataFileStat) bool {
+	return time.Since(stat.ModTime) >= p.MinAge && stat.Reads <= p.MaxReads
+}
+
+// MoveColdFiles 按照 TieringPolicy 将变冷的旧的数据文件迁移到 ColdDirPath，返回迁移的文件数量
+// 开启分层存储之后会在后台每隔 TieringInterval 调用一次，也可以手动调用
+// 迁移与 merge 不能同时进行
+func (db *DB) MoveColdFiles() (int, error) {
+	if db.options.ColdDirPath == "" {
+		return 0, nil
+	}
+
+	db.mu.Lock()
+	if db.isMerging {
+		db.mu.Unlock()
+		return 0, ErrMergeIsProgress
+	}
+	if db.isTiering {
+		db.mu.Unlock()
+		return 0, ErrTieringIsProgress
+	}
+	// 取出所有还在热存储目录中的旧的数据文件
+	var candidates []*data.DataFile
+	for _, dataFile := range db.olderFiles {
+		if dataFile.DirPath != db.options.ColdDirPath {
+			candidates = append(candidates, dataFile)
+		}
+	}
+	db.isTiering = true
+	db.mu.Unlock()
+	defer func() {
+		db.mu.Lock()
+		db.isTiering = false
+		db.mu.Unlock()
+	}()
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].FileID < candidates[j].FileID
+	})
+
+	var moved int
+	for _, dataFile := range candidates {
+		stat, err := db.dataFileStat(dataFile)
+		if err != nil {
+			return moved, err
+		}
+		if reads, ok := db.fileReads.LoadAndDelete(dataFile.FileID); ok {
+			stat.Reads = reads.(*metrics.Counter).Value()
+		}
+		if !db.options.TieringPolicy.IsCold(stat) {
+			continue
+		}
+		if err := db.moveToColdDir(dataFile); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	if moved > 0 {
+		db.options.Logger.Info("data files moved to cold dir",
+			logKeyDir, db.options.DirPath,
+			logKeyFiles, moved,
+		)
+	}
+	return moved, nil
+}
+
+// 将一个旧的数据文件迁移到冷存储目录，并替换 olderFiles 中的数据文件
+func (db *DB) moveToColdDir(dataFile *data.DataFile) error {
+	hotPath := data.GetDataFileName(dataFile.DirPath, dataFile.FileID)
+	coldPath := data.GetDataFileName(db.options.ColdDirPath, dataFile.FileID)
+
+	// 先拷贝到临时文件，拷贝完成之后再重命名，保证冷存储目录中的数据文件一定是完整的
+	tmpPath := coldPath + tieringTmpSuffix
+	if err := utils.CopyFile(hotPath, tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, coldPath); err != nil {
+		return err
+	}
+
+	coldFile, err := data.OpenDataFile(db.options.ColdDirPath, dataFile.FileID, db.fileIOType(false))
+	if err != nil {
+		return err
+	}
+	db.configureFile(coldFile)
+
+	// 读取数据时会持有读锁，替换之后不会再有读取旧文件的操作
+	db.mu.Lock()
+	db.olderFiles[dataFile.FileID] = coldFile
+	db.mu.Unlock()
+
+	if err := dataFile.Close(); err != nil {
+		return err
+	}
+	return os.Remove(hotPath)
+}
+
+// 记录一次从数据文件中的读取，用于按照访问频率判断冷热
+func (db *DB) recordFileRead(fid uint32) {
+	if db.options.ColdDirPath == "" {
+		return
+	}
+	counter, ok := db.fileReads.Load(fid)
+	if !ok {
+		counter, _ = db.fileReads.LoadOrStore(fid, new(metrics.Counter))
+	}
+	counter.(*metrics.Counter).Inc()
+}
+
+// 创建冷存储目录，并清理上一次没有完成迁移的临时文件
+func (db *DB) initColdDir() error {
+	if db.options.ColdDirPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(db.options.ColdDirPath, os.ModePerm); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(db.options.ColdDirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), tieringTmpSuffix) {
+			if err := os.Remove(filepath.Join(db.options.ColdDirPath, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 启动后台迁移冷数据文件的任务
+func (db *DB) startTiering() {
+	if db.options.ColdDirPath == "" || db.options.TieringInterval <= 0 {
+		return
+	}
+	db.tieringStop = make(chan struct{})
+	db.tieringDone = make(chan struct{})
+	go func() {
+		defer close(db.tieringDone)
+		ticker := time.NewTicker(db.options.TieringInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-db.tieringStop:
+				return
+			case <-ticker.C:
+				_, err := db.MoveColdFiles()
+				if err != nil && err != ErrMergeIsProgress && err != ErrTieringIsProgress {
+					db.options.Logger.Error("tiering failed", logKeyDir, db.options.DirPath, logKeyErr, err)
+				}
+			}
+		}
+	}()
+}
+
+// 停止后台迁移冷数据文件的任务，等待正在进行的迁移完成
+func (db *DB) stopTiering() {
+	if db.tieringStop == nil {
+		return
+	}
+	close(db.tieringStop)
+	<-db.tieringDone
+	db.tieringStop = nil
+}
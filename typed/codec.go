@@ -0,0 +1,90 @@
+package typed
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec 类型 T 与字节数组之间的编解码
+// 用于 key 的 Codec 在需要按照 key 有序遍历时，编码之后的字节序必须与 T 的自然顺序一致
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+// BytesCodec 不做任何转换的字节数组编解码，保持字节序
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// StringCodec 字符串编解码，保持字节序
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// JSONCodec 使用 encoding/json 的编解码，只适合用于 value
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec 使用 encoding/gob 的编解码，每个 value 单独编码，包含完整的类型信息，只适合用于 value
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// Message protobuf 风格的二进制消息，与 gogo/protobuf、vtprotobuf 等生成的 Marshal/Unmarshal 方法一致
+type Message interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+// ProtoCodec 使用消息自身的 Marshal/Unmarshal 方法编解码，PT 为消息的指针类型，只适合用于 value
+type ProtoCodec[T any, PT interface {
+	*T
+	Message
+}] struct{}
+
+func (ProtoCodec[T, PT]) Encode(v PT) ([]byte, error) {
+	return v.Marshal()
+}
+
+func (ProtoCodec[T, PT]) Decode(data []byte) (PT, error) {
+	v := PT(new(T))
+	if err := v.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,211 @@
+package typed
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrInvalidIntKey   = errors.New("invalid integer key, must be 8 bytes")
+	ErrInvalidTupleKey = errors.New("invalid tuple key")
+)
+
+// Integer 可以使用 IntCodec 编码的整数类型
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// IntCodec 整数的有序编码，统一编码为 8 字节的大端序，有符号整数翻转符号位，编码之后的字节序与数值大小顺序一致
+type IntCodec[T Integer] struct{}
+
+// 是否是有符号整数
+func signed[T Integer]() bool {
+	var zero T
+	return zero-1 < zero
+}
+
+func (IntCodec[T]) Encode(v T) ([]byte, error) {
+	buf := make([]byte, 8)
+	u := uint64(v)
+	if signed[T]() {
+		u ^= 1 << 63
+	}
+	binary.BigEndian.PutUint64(buf, u)
+	return buf, nil
+}
+
+func (IntCodec[T]) Decode(data []byte) (T, error) {
+	if len(data) != 8 {
+		return 0, ErrInvalidIntKey
+	}
+	u := binary.BigEndian.Uint64(data)
+	if signed[T]() {
+		u ^= 1 << 63
+	}
+	return T(u), nil
+}
+
+// 元组中的每个元素编码之后进行转义，0x00 转义为 0x00 0xff，并以 0x00 0x01 结尾
+// 这样每个元素都可以被单独解析出来，并且整个元组的字节序与逐个元素比较的顺序一致
+const (
+	tupleEscape     byte = 0x00
+	tupleEscapedNul byte = 0xff
+	tupleTerminator byte = 0x01
+)
+
+func appendTupleElement(dst, elem []byte) []byte {
+	for _, b := range elem {
+		dst = append(dst, b)
+		if b == tupleEscape {
+			dst = append(dst, tupleEscapedNul)
+		}
+	}
+	return append(dst, tupleEscape, tupleTerminator)
+}
+
+// 解析元组中的下一个元素，返回元素和剩余的部分
+func readTupleElement(data []byte) ([]byte, []byte, error) {
+	var elem []byte
+	for i := 0; i < len(data); i++ {
+		if data[i] != tupleEscape {
+			elem = append(elem, data[i])
+			continue
+		}
+		if i+1 >= len(data) {
+			return nil, nil, ErrInvalidTupleKey
+		}
+		switch data[i+1] {
+		case tupleEscapedNul:
+			elem = append(elem, tupleEscape)
+			i++
+		case tupleTerminator:
+			return elem, data[i+2:], nil
+		default:
+			return nil, nil, ErrInvalidTupleKey
+		}
+	}
+	return nil, nil, ErrInvalidTupleKey
+}
+
+// 使用 codec 编码一个元素并追加到 dst 之后
+func encodeTupleElement[T any](dst []byte, codec Codec[T], v T) ([]byte, error) {
+	elem, err := codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendTupleElement(dst, elem), nil
+}
+
+// 解析并使用 codec 解码下一个元素
+func decodeTupleElement[T any](data []byte, codec Codec[T]) (T, []byte, error) {
+	var v T
+	elem, rest, err := readTupleElement(data)
+	if err != nil {
+		return v, nil, err
+	}
+	v, err = codec.Decode(elem)
+	return v, rest, err
+}
+
+// Tuple2 两个元素的元组
+type Tuple2[A, B any] struct {
+	First  A
+	Second B
+}
+
+// Tuple2Codec 两个元素的元组的有序编码，元素的 Codec 需要保持字节序
+type Tuple2Codec[A, B any] struct {
+	First  Codec[A]
+	Second Codec[B]
+}
+
+// NewTuple2Codec 根据每个元素的 Codec 初始化 Tuple2Codec
+func NewTuple2Codec[A, B any](first Codec[A], second Codec[B]) Tuple2Codec[A, B] {
+	return Tuple2Codec[A, B]{First: first, Second: second}
+}
+
+func (c Tuple2Codec[A, B]) Encode(v Tuple2[A, B]) ([]byte, error) {
+	buf, err := encodeTupleElement(nil, c.First, v.First)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTupleElement(buf, c.Second, v.Second)
+}
+
+func (c Tuple2Codec[A, B]) Decode(data []byte) (Tuple2[A, B], error) {
+	var v Tuple2[A, B]
+	var err error
+	if v.First, data, err = decodeTupleElement(data, c.First); err != nil {
+		return v, err
+	}
+	if v.Second, data, err = decodeTupleElement(data, c.Second); err != nil {
+		return v, err
+	}
+	if len(data) != 0 {
+		return v, ErrInvalidTupleKey
+	}
+	return v, nil
+}
+
+// Prefix 第一个元素为 first 的所有元组编码之后共同的前缀，用于按前缀遍历
+func (c Tuple2Codec[A, B]) Prefix(first A) ([]byte, error) {
+	return encodeTupleElement(nil, c.First, first)
+}
+
+// Tuple3 三个元素的元组
+type Tuple3[A, B, C any] struct {
+	First  A
+	Second B
+	Third  C
+}
+
+// Tuple3Codec 三个元素的元组的有序编码，元素的 Codec 需要保持字节序
+type Tuple3Codec[A, B, C any] struct {
+	First  Codec[A]
+	Second Codec[B]
+	Third  Codec[C]
+}
+
+// NewTuple3Codec 根据每个元素的 Codec 初始化 Tuple3Codec
+func NewTuple3Codec[A, B, C any](first Codec[A], second Codec[B], third Codec[C]) Tuple3Codec[A, B, C] {
+	return Tuple3Codec[A, B, C]{First: first, Second: second, Third: third}
+}
+
+func (c Tuple3Codec[A, B, C]) Encode(v Tuple3[A, B, C]) ([]byte, error) {
+	buf, err := encodeTupleElement(nil, c.First, v.First)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = encodeTupleElement(buf, c.Second, v.Second); err != nil {
+		return nil, err
+	}
+	return encodeTupleElement(buf, c.Third, v.Third)
+}
+
+func (c Tuple3Codec[A, B, C]) Decode(data []byte) (Tuple3[A, B, C], error) {
+	var v Tuple3[A, B, C]
+	var err error
+	if v.First, data, err = decodeTupleElement(data, c.First); err != nil {
+		return v, err
+	}
+	if v.Second, data, err = decodeTupleElement(data, c.Second); err != nil {
+		return v, err
+	}
+	if v.Third, data, err = decodeTupleElement(data, c.Third); err != nil {
+		return v, err
+	}
+	if len(data) != 0 {
+		return v, ErrInvalidTupleKey
+	}
+	return v, nil
+}
+
+// Prefix 前两个元素为 first 和 second 的所有元组编码之后共同的前缀，用于按前缀遍历
+func (c Tuple3Codec[A, B, C]) Prefix(first A, second B) ([]byte, error) {
+	buf, err := encodeTupleElement(nil, c.First, first)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTupleElement(buf, c.Second, second)
+}
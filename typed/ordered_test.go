@@ -0,0 +1,85 @@
+package typed
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestIntCodec(t *testing.T) {
+	values := []int64{math.MinInt64, -1000, -1, 0, 1, 255, 256, math.MaxInt64}
+	codec := IntCodec[int64]{}
+	var encoded [][]byte
+	for _, v := range values {
+		buf, err := codec.Encode(v)
+		assert.Nil(t, err)
+		decoded, err := codec.Decode(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, v, decoded)
+		encoded = append(encoded, buf)
+	}
+	// 字节序与数值大小顺序一致
+	assert.True(t, sort.SliceIsSorted(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	}))
+
+	ucodec := IntCodec[uint32]{}
+	small, _ := ucodec.Encode(1)
+	large, _ := ucodec.Encode(math.MaxUint32)
+	assert.True(t, bytes.Compare(small, large) < 0)
+	v, err := ucodec.Decode(large)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(math.MaxUint32), v)
+
+	_, err = codec.Decode([]byte{1, 2})
+	assert.Equal(t, ErrInvalidIntKey, err)
+}
+
+func TestTupleCodec(t *testing.T) {
+	codec := NewTuple2Codec[string, int64](StringCodec{}, IntCodec[int64]{})
+	tuples := []Tuple2[string, int64]{
+		{"", 0},
+		{"a", -1},
+		{"a", 5},
+		{"a\x00", -100},
+		{"a\x00b", 0},
+		{"ab", math.MinInt64},
+		{"b", 0},
+	}
+	var encoded [][]byte
+	for _, tuple := range tuples {
+		buf, err := codec.Encode(tuple)
+		assert.Nil(t, err)
+		decoded, err := codec.Decode(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, tuple, decoded)
+		encoded = append(encoded, buf)
+	}
+	// 字节序与逐个元素比较的顺序一致
+	assert.True(t, sort.SliceIsSorted(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	}))
+
+	// 前缀只匹配第一个元素完全相同的元组
+	prefix, err := codec.Prefix("a")
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(encoded[1], prefix))
+	assert.True(t, bytes.HasPrefix(encoded[2], prefix))
+	assert.False(t, bytes.HasPrefix(encoded[3], prefix))
+
+	_, err = codec.Decode(encoded[1][:3])
+	assert.Equal(t, ErrInvalidTupleKey, err)
+
+	codec3 := NewTuple3Codec[uint8, string, []byte](IntCodec[uint8]{}, StringCodec{}, BytesCodec{})
+	tuple3 := Tuple3[uint8, string, []byte]{7, "name", []byte{0, 0, 1}}
+	buf, err := codec3.Encode(tuple3)
+	assert.Nil(t, err)
+	decoded, err := codec3.Decode(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, tuple3, decoded)
+	prefix, err = codec3.Prefix(7, "name")
+	assert.Nil(t, err)
+	assert.True(t, bytes.HasPrefix(buf, prefix))
+}
@@ -0,0 +1,147 @@
+package typed
+
+import (
+	bitcask_go "myRosedb"
+)
+
+// Store 在 *bitcask_go.DB 之上按照 K、V 类型读写数据，key 和 value 分别使用对应的 Codec 编解码
+// 多个 Store 可以共用同一个 DB，不同 Store 的 key 需要通过前缀等方式区分
+type Store[K, V any] struct {
+	db     *bitcask_go.DB
+	keys   Codec[K]
+	values Codec[V]
+}
+
+// NewStore 初始化 Store
+func NewStore[K, V any](db *bitcask_go.DB, keys Codec[K], values Codec[V]) *Store[K, V] {
+	return &Store[K, V]{db: db, keys: keys, values: values}
+}
+
+// DB 获取底层的存储引擎实例
+func (s *Store[K, V]) DB() *bitcask_go.DB {
+	return s.db
+}
+
+// Get 根据 key 读取数据，key 不存在时返回 bitcask_go.ErrKeyNotFound
+func (s *Store[K, V]) Get(key K) (V, error) {
+	var value V
+	encKey, err := s.keys.Encode(key)
+	if err != nil {
+		return value, err
+	}
+	encValue, err := s.db.Get(encKey)
+	if err != nil {
+		return value, err
+	}
+	return s.values.Decode(encValue)
+}
+
+// Put 写入数据
+func (s *Store[K, V]) Put(key K, value V) error {
+	encKey, err := s.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	encValue, err := s.values.Encode(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(encKey, encValue)
+}
+
+// Delete 删除数据
+func (s *Store[K, V]) Delete(key K) error {
+	encKey, err := s.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	return s.db.Delete(encKey)
+}
+
+// Iterate 按照编码之后的 key 的字节序遍历数据，fn 返回 false 时终止遍历
+// opts.Prefix 为编码之后的前缀，元组可以通过 Tuple2Codec.Prefix 等方法获取
+// 同一个 DB 中有其他 Store 的数据时，需要通过 opts.Prefix 限定范围，否则会因为解码失败返回错误
+func (s *Store[K, V]) Iterate(opts bitcask_go.IteratorOptions, fn func(key K, value V) bool) error {
+	iterator := s.db.NewIterator(opts)
+	defer iterator.Close()
+	for iterator.Rewind(); iterator.Valid(); iterator.Next() {
+		key, err := s.keys.Decode(iterator.Key())
+		if err != nil {
+			return err
+		}
+		encValue, err := iterator.Value()
+		if err != nil {
+			return err
+		}
+		value, err := s.values.Decode(encValue)
+		if err != nil {
+			return err
+		}
+		if !fn(key, value) {
+			break
+		}
+	}
+	return iterator.Err()
+}
+
+// NewBatch 初始化按照 K、V 类型写入的 WriteBatch
+func (s *Store[K, V]) NewBatch(opts bitcask_go.WriteBatchOptions) *Batch[K, V] {
+	return &Batch[K, V]{store: s, wb: s.db.NewWriteBatch(opts)}
+}
+
+// Batch 按照 K、V 类型写入的 WriteBatch
+type Batch[K, V any] struct {
+	store *Store[K, V]
+	wb    *bitcask_go.WriteBatch
+}
+
+// Put 批量写数据
+func (b *Batch[K, V]) Put(key K, value V) error {
+	encKey, err := b.store.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	encValue, err := b.store.values.Encode(value)
+	if err != nil {
+		return err
+	}
+	return b.wb.Put(encKey, encValue)
+}
+
+// Delete 批量删除数据
+func (b *Batch[K, V]) Delete(key K) error {
+	encKey, err := b.store.keys.Encode(key)
+	if err != nil {
+		return err
+	}
+	return b.wb.Delete(encKey)
+}
+
+// Get 读取 key 对应的数据，优先读取批次中还没有提交的数据
+func (b *Batch[K, V]) Get(key K) (V, error) {
+	var value V
+	encKey, err := b.store.keys.Encode(key)
+	if err != nil {
+		return value, err
+	}
+	encValue, err := b.wb.Get(encKey)
+	if err != nil {
+		return value, err
+	}
+	return b.store.values.Decode(encValue)
+}
+
+// Len 批次中暂存的操作数量
+func (b *Batch[K, V]) Len() int {
+	return b.wb.Len()
+}
+
+// Commit 提交批次中的数据
+func (b *Batch[K, V]) Commit() error {
+	return b.wb.Commit()
+}
+
+// Rollback 丢弃批次中所有还没有提交的数据
+func (b *Batch[K, V]) Rollback() {
+	b.wb.Rollback()
+}
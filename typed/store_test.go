@@ -0,0 +1,119 @@
+package typed
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	bitcask_go "myRosedb"
+	"os"
+	"testing"
+)
+
+type user struct {
+	Name string
+	Age  int
+}
+
+// 模拟 protobuf 生成的消息类型
+type point struct {
+	X, Y int32
+}
+
+func (p *point) Marshal() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint32(buf, uint32(p.X))
+	binary.BigEndian.PutUint32(buf[4:], uint32(p.Y))
+	return buf, nil
+}
+
+func (p *point) Unmarshal(data []byte) error {
+	if len(data) != 8 {
+		return errors.New("invalid point")
+	}
+	p.X = int32(binary.BigEndian.Uint32(data))
+	p.Y = int32(binary.BigEndian.Uint32(data[4:]))
+	return nil
+}
+
+func openTestDB(t *testing.T) *bitcask_go.DB {
+	opts := bitcask_go.DefaultOptions
+	dir, _ := os.MkdirTemp("", "bitcask-go-typed")
+	opts.DirPath = dir
+	db, err := bitcask_go.Open(opts)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	})
+	return db
+}
+
+func TestStore(t *testing.T) {
+	db := openTestDB(t)
+	keys := NewTuple2Codec[string, int64](StringCodec{}, IntCodec[int64]{})
+	store := NewStore[Tuple2[string, int64], user](db, keys, JSONCodec[user]{})
+
+	for _, id := range []int64{3, -1, 2} {
+		err := store.Put(Tuple2[string, int64]{"tenant-a", id}, user{Name: "a", Age: int(id)})
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, store.Put(Tuple2[string, int64]{"tenant-b", 1}, user{Name: "b"}))
+
+	u, err := store.Get(Tuple2[string, int64]{"tenant-a", 2})
+	assert.Nil(t, err)
+	assert.Equal(t, user{Name: "a", Age: 2}, u)
+	_, err = store.Get(Tuple2[string, int64]{"tenant-a", 100})
+	assert.Equal(t, bitcask_go.ErrKeyNotFound, err)
+
+	// 按照前缀有序遍历
+	prefix, err := keys.Prefix("tenant-a")
+	assert.Nil(t, err)
+	var ids []int64
+	err = store.Iterate(bitcask_go.IteratorOptions{Prefix: prefix}, func(key Tuple2[string, int64], value user) bool {
+		ids = append(ids, key.Second)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{-1, 2, 3}, ids)
+
+	assert.Nil(t, store.Delete(Tuple2[string, int64]{"tenant-a", -1}))
+	_, err = store.Get(Tuple2[string, int64]{"tenant-a", -1})
+	assert.Equal(t, bitcask_go.ErrKeyNotFound, err)
+
+	// 批量写入
+	batch := store.NewBatch(bitcask_go.DefaultWriteBatchOptions)
+	assert.Nil(t, batch.Put(Tuple2[string, int64]{"tenant-c", 1}, user{Name: "c"}))
+	assert.Nil(t, batch.Delete(Tuple2[string, int64]{"tenant-b", 1}))
+	u, err = batch.Get(Tuple2[string, int64]{"tenant-c", 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "c", u.Name)
+	assert.Equal(t, 2, batch.Len())
+	assert.Nil(t, batch.Commit())
+	_, err = store.Get(Tuple2[string, int64]{"tenant-b", 1})
+	assert.Equal(t, bitcask_go.ErrKeyNotFound, err)
+	u, err = store.Get(Tuple2[string, int64]{"tenant-c", 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "c", u.Name)
+}
+
+func TestStore_Codecs(t *testing.T) {
+	db := openTestDB(t)
+
+	points := NewStore[uint64, *point](db, IntCodec[uint64]{}, ProtoCodec[point, *point]{})
+	assert.Nil(t, points.Put(1, &point{X: -3, Y: 4}))
+	p, err := points.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, &point{X: -3, Y: 4}, p)
+
+	gobs := NewStore[string, map[string]int](db, StringCodec{}, GobCodec[map[string]int]{})
+	assert.Nil(t, gobs.Put("counts", map[string]int{"a": 1, "b": 2}))
+	counts, err := gobs.Get("counts")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2}, counts)
+
+	raw := NewStore[[]byte, []byte](db, BytesCodec{}, BytesCodec{})
+	assert.Nil(t, raw.Put([]byte("raw"), []byte("value")))
+	val, err := raw.Get([]byte("raw"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
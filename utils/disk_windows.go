@@ -0,0 +1,18 @@
+//go:build windows
+
+package utils
+
+import "golang.org/x/sys/windows"
+
+// AvailableDiskSize 获取目录所在磁盘剩余的可用空间大小
+func AvailableDiskSize(dirPath string) (uint64, error) {
+	path, err := windows.UTF16PtrFromString(dirPath)
+	if err != nil {
+		return 0, err
+	}
+	var freeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &freeBytes, nil, nil); err != nil {
+		return 0, err
+	}
+	return freeBytes, nil
+}
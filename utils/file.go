@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -22,35 +24,13 @@ func DirSize(dirPath string) (int64, error) {
 	return size, err
 }
 
-//// AvailableDiskSize 磁盘剩余的可用空间大小
-//func AvailableDiskSize() (uint64, error) {
-//	wd, err := syscall.Getwd()
-//	if err != nil {
-//		return 0, err
-//	}
-//	fs.StatFS()
-//	var stat syscall.Statfs_t
-//	if err = syscall.Statfs(wd, &stat); err != nil {
-//		return 0, err
-//	}
-//	return stat.Bavail * uint64(stat.Bsize), nil
-//}
-//
-//func AvailableDiskSize2() (uint64, error) {
-//	wd, err := syscall.Getwd()
-//	windows.Getwd()
-//	if err != nil {
-//		return 0, err
-//	}
-//	var stat syscall.Statfs_t
-//	if err = syscall.Statfs(wd, &stat); err != nil {
-//		return 0, err
-//	}
-//	return stat.Bavail * uint64(stat.Bsize), nil
-//}
-
 // CopyDIr 拷贝数据目录
 func CopyDir(src, dest string, exclude []string) error {
+	return CopyDirCtx(context.Background(), src, dest, exclude)
+}
+
+// CopyDirCtx 拷贝数据目录，每拷贝一个文件前检查 ctx 是否已经被取消
+func CopyDirCtx(ctx context.Context, src, dest string, exclude []string) error {
 	// 目标不存在则创建
 	if _, err := os.Stat(dest); os.IsNotExist(err) {
 		if err := os.MkdirAll(dest, os.ModePerm); err != nil {
@@ -58,6 +38,9 @@ func CopyDir(src, dest string, exclude []string) error {
 		}
 	}
 	return filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		fileName := strings.Replace(path, src, "", 1)
 		if fileName == "" {
 			return nil
@@ -82,3 +65,26 @@ func CopyDir(src, dest string, exclude []string) error {
 		return os.WriteFile(filepath.Join(dest, fileName), data, info.Mode())
 	})
 }
+
+// CopyFile 拷贝单个文件，并持久化到磁盘
+func CopyFile(src, dest string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destFile, srcFile); err != nil {
+		_ = destFile.Close()
+		return err
+	}
+	if err := destFile.Sync(); err != nil {
+		_ = destFile.Close()
+		return err
+	}
+	return destFile.Close()
+}
@@ -12,3 +12,10 @@ func TestDirSize(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, dirSize > 0)
 }
+
+func TestAvailableDiskSize(t *testing.T) {
+	dir, _ := os.Getwd()
+	size, err := AvailableDiskSize(dir)
+	assert.Nil(t, err)
+	assert.True(t, size > 0)
+}
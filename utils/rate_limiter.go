@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter 令牌桶限速，每秒产生 rate 个令牌，最多积攒 burst 个，并发安全
+// 一次请求的数量可以超过 burst，不足的部分会先欠下，等待令牌补足之后返回
+// 所有方法都可以在 nil 上调用，相当于不限速
+type RateLimiter struct {
+	mu     *sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 初始化令牌桶，rate 为每秒产生的令牌数量，初始时桶是满的
+func NewRateLimiter(rate, burst int64) *RateLimiter {
+	if burst < rate {
+		burst = rate
+	}
+	return &RateLimiter{
+		mu:     new(sync.Mutex),
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// WaitN 取出 n 个令牌，令牌不足时等待，ctx 被取消时返回 ctx.Err()
+func (l *RateLimiter) WaitN(ctx context.Context, n int64) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	wait := l.reserve(n)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// 扣除 n 个令牌，返回需要等待的时间
+func (l *RateLimiter) reserve(n int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
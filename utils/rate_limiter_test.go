@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_WaitN(t *testing.T) {
+	limiter := NewRateLimiter(1000, 1000)
+	start := time.Now()
+	// 桶中初始的令牌可以直接使用
+	err := limiter.WaitN(context.Background(), 1000)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+
+	// 超过 burst 的请求等待令牌补足
+	err = limiter.WaitN(context.Background(), 200)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 150*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = limiter.WaitN(ctx, 10000)
+	assert.Equal(t, context.Canceled, err)
+
+	// nil 表示不限速
+	var unlimited *RateLimiter
+	assert.Nil(t, unlimited.WaitN(context.Background(), 1<<30))
+}
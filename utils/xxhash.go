@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// xxHash64 算法的常量，参考 https://github.com/Cyan4973/xxHash
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXHash64 流式计算 xxHash64（seed 为 0），实现了 hash.Hash64 接口
+type XXHash64 struct {
+	v1, v2, v3, v4 uint64
+	total          uint64   // 累计写入的字节数
+	mem            [32]byte // 不足 32 字节的剩余数据
+	n              int      // mem 中剩余数据的长度
+}
+
+var _ hash.Hash64 = (*XXHash64)(nil)
+
+// NewXXHash64 初始化 xxHash64
+func NewXXHash64() *XXHash64 {
+	x := &XXHash64{}
+	x.Reset()
+	return x
+}
+
+// XXHash64Sum 计算 b 的 xxHash64 值
+func XXHash64Sum(b []byte) uint64 {
+	x := NewXXHash64()
+	_, _ = x.Write(b)
+	return x.Sum64()
+}
+
+func (x *XXHash64) Reset() {
+	// 常量直接相加会溢出，所以借助变量进行回绕运算
+	prime1, prime2 := xxPrime1, xxPrime2
+	x.v1 = prime1 + prime2
+	x.v2 = prime2
+	x.v3 = 0
+	x.v4 = -prime1
+	x.total = 0
+	x.n = 0
+}
+
+func (x *XXHash64) Size() int { return 8 }
+
+func (x *XXHash64) BlockSize() int { return 32 }
+
+func (x *XXHash64) Write(b []byte) (int, error) {
+	length := len(b)
+	x.total += uint64(length)
+
+	// 先把上一次剩余的数据补齐 32 字节
+	if x.n+len(b) < 32 {
+		x.n += copy(x.mem[x.n:], b)
+		return length, nil
+	}
+	if x.n > 0 {
+		c := copy(x.mem[x.n:], b)
+		x.v1 = xxRound(x.v1, binary.LittleEndian.Uint64(x.mem[0:8]))
+		x.v2 = xxRound(x.v2, binary.LittleEndian.Uint64(x.mem[8:16]))
+		x.v3 = xxRound(x.v3, binary.LittleEndian.Uint64(x.mem[16:24]))
+		x.v4 = xxRound(x.v4, binary.LittleEndian.Uint64(x.mem[24:32]))
+		b = b[c:]
+		x.n = 0
+	}
+
+	for ; len(b) >= 32; b = b[32:] {
+		x.v1 = xxRound(x.v1, binary.LittleEndian.Uint64(b[0:8]))
+		x.v2 = xxRound(x.v2, binary.LittleEndian.Uint64(b[8:16]))
+		x.v3 = xxRound(x.v3, binary.LittleEndian.Uint64(b[16:24]))
+		x.v4 = xxRound(x.v4, binary.LittleEndian.Uint64(b[24:32]))
+	}
+	x.n = copy(x.mem[:], b)
+	return length, nil
+}
+
+func (x *XXHash64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, x.Sum64())
+}
+
+func (x *XXHash64) Sum64() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		h = bits.RotateLeft64(x.v1, 1) + bits.RotateLeft64(x.v2, 7) +
+			bits.RotateLeft64(x.v3, 12) + bits.RotateLeft64(x.v4, 18)
+		h = xxMergeRound(h, x.v1)
+		h = xxMergeRound(h, x.v2)
+		h = xxMergeRound(h, x.v3)
+		h = xxMergeRound(h, x.v4)
+	} else {
+		h = x.v3 + xxPrime5
+	}
+	h += x.total
+
+	p := x.mem[:x.n]
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		p = p[4:]
+	}
+	for _, c := range p {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestXXHash64Sum(t *testing.T) {
+	assert.Equal(t, uint64(0xef46db3751d8e999), XXHash64Sum([]byte("")))
+	assert.Equal(t, uint64(0xd24ec4f1a98c6e5b), XXHash64Sum([]byte("a")))
+	assert.Equal(t, uint64(0x44bc2cf5ad770999), XXHash64Sum([]byte("abc")))
+	assert.Equal(t, uint64(0xfbcea83c8a378bf1), XXHash64Sum([]byte("Nobody inspects the spammish repetition")))
+}
+
+func TestXXHash64_Write(t *testing.T) {
+	buf := RandomValue(1000)
+	expected := XXHash64Sum(buf)
+
+	// 分多次写入的结果与一次写入相同
+	for _, step := range []int{1, 7, 31, 32, 33, 100} {
+		x := NewXXHash64()
+		for i := 0; i < len(buf); i += step {
+			end := i + step
+			if end > len(buf) {
+				end = len(buf)
+			}
+			_, _ = x.Write(buf[i:end])
+		}
+		assert.Equal(t, expected, x.Sum64())
+	}
+}